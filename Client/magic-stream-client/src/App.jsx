@@ -22,9 +22,7 @@ function App() {
 
   const handleLogout = async () => {
     try {
-      const response = await axiosClient.post("/logout", {
-        user_id: auth.user_id,
-      });
+      await axiosClient.post("/logout");
       setAuth(null);
     //   localStorage.removeItem("user");
       console.log("user logged out");
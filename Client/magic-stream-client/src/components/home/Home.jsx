@@ -14,8 +14,8 @@ const Home = ({updateMovieReview}) => {
 
       try {
         const response = await axiosClient.get("/movies");
-        setMovies(response.data);
-        if (response.data.length === 0) {
+        setMovies(response.data.movies);
+        if (response.data.movies.length === 0) {
           setMessage("There are currently no movies available");
         }
       } catch (error) {
@@ -0,0 +1,58 @@
+// Package classifier turns free-text movie reviews into one of the rankings
+// stored in the rankings collection.
+package classifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/joho/godotenv"
+)
+
+// ReviewClassifier picks the ranking that best describes a review. rankings
+// only contains the rankings the classifier is allowed to choose from.
+type ReviewClassifier interface {
+	Classify(ctx context.Context, review string, rankings []models.Ranking) (models.Ranking, error)
+}
+
+// NewFromEnv builds the classifier selected by REVIEW_CLASSIFIER:
+//
+//	openai  - any OpenAI-compatible chat completions API (default)
+//	ollama  - a local Ollama-style /api/chat endpoint
+//	lexicon - the offline keyword classifier
+func NewFromEnv() (ReviewClassifier, error) {
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Println("Warning: .env file not found")
+	}
+
+	switch kind := strings.ToLower(os.Getenv("REVIEW_CLASSIFIER")); kind {
+	case "", "openai", "openrouter":
+		return newOpenAIFromEnv()
+	case "ollama":
+		return newOllamaFromEnv()
+	case "lexicon":
+		return NewLexiconClassifier(), nil
+	default:
+		return nil, fmt.Errorf("unknown REVIEW_CLASSIFIER %q", kind)
+	}
+}
+
+// BuildPrompt fills the {rankings} placeholder of template with the comma
+// separated ranking names.
+func BuildPrompt(template string, rankings []models.Ranking) string {
+	return strings.Replace(template, "{rankings}", strings.Join(rankingNames(rankings), ","), 1)
+}
+
+func promptTemplateFromEnv() (string, error) {
+	basePromptTemplate := os.Getenv("BASE_PROMPT_TEMPLATE")
+	if basePromptTemplate == "" {
+		return "", errors.New("could not read BASE_PROMPT_TEMPLATE")
+	}
+	return basePromptTemplate, nil
+}
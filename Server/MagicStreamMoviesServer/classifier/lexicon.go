@@ -0,0 +1,112 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+// negationWindow is how many words after a negator have their polarity flipped.
+const negationWindow = 3
+
+var sentimentLexicon = map[string]float64{
+	"masterpiece": 3, "sublime": 3, "brilliant": 3, "outstanding": 3, "superb": 3,
+	"excellent": 3, "amazing": 3, "perfect": 3, "loved": 3, "love": 3, "stunning": 3,
+	"fantastic": 3, "wonderful": 3, "incredible": 3, "phenomenal": 3, "flawless": 3,
+	"lovely": 2.5, "great": 2, "beautiful": 2, "enjoyed": 2, "enjoy": 2, "fun": 2, "funny": 2,
+	"hilarious": 2, "gripping": 2, "moving": 2, "impressive": 2, "compelling": 2,
+	"recommend": 2, "memorable": 2, "charming": 2, "clever": 2, "delightful": 2,
+	"good": 1.5, "nice": 1, "solid": 1, "liked": 1.5, "like": 1, "entertaining": 1.5,
+	"decent": 0.5, "fine": 0.5, "watchable": 0.5, "ok": 0, "okay": 0, "average": -0.5,
+	"mediocre": -1, "forgettable": -1, "predictable": -1, "slow": -1, "long": -0.5,
+	"dull": -1.5, "boring": -2, "weak": -1.5, "bland": -1.5, "disappointing": -2,
+	"disappointed": -2, "bad": -2, "poor": -2, "mess": -2, "messy": -1.5,
+	"hate": -3, "hated": -3, "awful": -3, "terrible": -3, "horrible": -3, "worst": -3,
+	"garbage": -3, "trash": -3, "unwatchable": -3, "dreadful": -3, "atrocious": -3,
+	"waste": -2.5, "painful": -2, "stupid": -2, "annoying": -2, "pointless": -2,
+}
+
+var negators = map[string]bool{
+	"not": true, "no": true, "never": true, "nothing": true, "hardly": true,
+	"didn't": true, "don't": true, "doesn't": true, "isn't": true, "wasn't": true,
+	"aren't": true, "weren't": true, "can't": true, "couldn't": true, "won't": true,
+	"wouldn't": true, "shouldn't": true, "without": true,
+}
+
+var intensifiers = map[string]float64{
+	"very": 1.5, "really": 1.5, "so": 1.3, "absolutely": 1.8, "extremely": 1.8,
+	"incredibly": 1.8, "truly": 1.5, "totally": 1.5, "quite": 1.2, "pretty": 1.1,
+	"slightly": 0.6, "somewhat": 0.7, "bit": 0.7,
+}
+
+// LexiconClassifier ranks reviews with a fixed word list. It needs no network
+// access, which makes it the classifier for tests and air-gapped deployments.
+type LexiconClassifier struct{}
+
+func NewLexiconClassifier() *LexiconClassifier {
+	return &LexiconClassifier{}
+}
+
+func (l *LexiconClassifier) Classify(ctx context.Context, review string, rankings []models.Ranking) (models.Ranking, error) {
+	if len(rankings) == 0 {
+		return models.Ranking{}, errors.New("lexicon: no rankings to choose from")
+	}
+
+	// Lower ranking values are better, so sorting ascending orders the
+	// rankings from most positive to most negative.
+	ordered := append([]models.Ranking(nil), rankings...)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].RankingValue < ordered[j].RankingValue
+	})
+
+	polarity := Polarity(review)
+	index := int(math.Round((1 - polarity) / 2 * float64(len(ordered)-1)))
+	return ordered[index], nil
+}
+
+// Polarity scores text between -1 (very negative) and 1 (very positive).
+func Polarity(text string) float64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\'' && r != '’'
+	})
+
+	score := 0.0
+	negated := 0
+	boost := 1.0
+	for _, word := range words {
+		word = strings.ReplaceAll(word, "’", "'")
+
+		if negators[word] {
+			negated = negationWindow
+			continue
+		}
+		if factor, ok := intensifiers[word]; ok {
+			boost *= factor
+			continue
+		}
+
+		if weight, ok := sentimentLexicon[word]; ok {
+			weight *= boost
+			if negated > 0 {
+				// "not great" is mildly negative rather than the opposite of great.
+				weight *= -0.6
+			}
+			score += weight
+		}
+
+		boost = 1.0
+		if negated > 0 {
+			negated--
+		}
+	}
+
+	// Squash the unbounded sum into [-1, 1]; alpha controls how quickly long
+	// reviews saturate.
+	const alpha = 4.0
+	return score / math.Sqrt(score*score+alpha)
+}
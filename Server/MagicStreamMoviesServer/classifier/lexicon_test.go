@@ -0,0 +1,78 @@
+package classifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+var testRankings = []models.Ranking{
+	{RankingValue: 3, RankingName: "Okay"},
+	{RankingValue: 1, RankingName: "Excellent"},
+	{RankingValue: 5, RankingName: "Terrible"},
+	{RankingValue: 2, RankingName: "Good"},
+	{RankingValue: 4, RankingName: "Bad"},
+}
+
+func TestLexiconClassifier(t *testing.T) {
+	tests := []struct {
+		review string
+		want   string
+	}{
+		{"An absolute masterpiece, I loved every minute of it.", "Excellent"},
+		{"A decent story with some nice moments.", "Good"},
+		{"It was a film.", "Okay"},
+		{"Not great, a bit dull.", "Bad"},
+		{"The worst, most boring garbage I have ever seen.", "Terrible"},
+		{"I didn’t enjoy it at all.", "Bad"},
+	}
+
+	l := NewLexiconClassifier()
+	for _, tt := range tests {
+		got, err := l.Classify(context.Background(), tt.review, testRankings)
+		if err != nil {
+			t.Fatalf("Classify(%q): %v", tt.review, err)
+		}
+		if got.RankingName != tt.want {
+			t.Errorf("Classify(%q) = %s, want %s", tt.review, got.RankingName, tt.want)
+		}
+	}
+}
+
+func TestLexiconClassifierNeedsRankings(t *testing.T) {
+	if _, err := NewLexiconClassifier().Classify(context.Background(), "great", nil); err == nil {
+		t.Error("Classify with no rankings succeeded")
+	}
+}
+
+func TestPolarity(t *testing.T) {
+	tests := []struct {
+		text     string
+		positive bool
+		negative bool
+	}{
+		{"great", true, false},
+		{"not great", false, true},
+		{"not bad", true, false},
+		{"", false, false},
+		{"the plot and the cast", false, false},
+	}
+
+	for _, tt := range tests {
+		p := Polarity(tt.text)
+		if p < -1 || p > 1 {
+			t.Errorf("Polarity(%q) = %v, out of [-1, 1]", tt.text, p)
+		}
+		if (p > 0) != tt.positive || (p < 0) != tt.negative {
+			t.Errorf("Polarity(%q) = %v, want positive=%v negative=%v", tt.text, p, tt.positive, tt.negative)
+		}
+	}
+
+	if Polarity("really great") <= Polarity("great") {
+		t.Error("an intensifier did not strengthen the polarity")
+	}
+	if Polarity("great great great great") >= 1 {
+		t.Error("polarity of a long review reached 1")
+	}
+}
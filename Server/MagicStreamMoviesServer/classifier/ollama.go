@@ -0,0 +1,96 @@
+package classifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+// OllamaClassifier calls a local Ollama-style /api/chat endpoint, so reviews
+// can be ranked without sending them to a hosted provider.
+type OllamaClassifier struct {
+	BaseURL        string
+	Model          string
+	PromptTemplate string
+	HTTPClient     *http.Client
+}
+
+func newOllamaFromEnv() (*OllamaClassifier, error) {
+	basePromptTemplate, err := promptTemplateFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := os.Getenv("OLLAMA_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	model := os.Getenv("AI_MODEL")
+	if model == "" {
+		model = "llama3.2"
+	}
+
+	return &OllamaClassifier{
+		BaseURL:        baseURL,
+		Model:          model,
+		PromptTemplate: basePromptTemplate,
+		// Local models can be slow to load on first use.
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func (o *OllamaClassifier) Classify(ctx context.Context, review string, rankings []models.Ranking) (models.Ranking, error) {
+	return classifyStructured(ctx, o.chat, BuildPrompt(o.PromptTemplate, rankings), review, rankings)
+}
+
+func (o *OllamaClassifier) chat(ctx context.Context, messages []chatMessage, schema map[string]interface{}) (string, error) {
+	reqBody := map[string]interface{}{
+		"model":    o.Model,
+		"messages": messages,
+		"format":   schema,
+		"stream":   false,
+		"options":  map[string]interface{}{"temperature": 0},
+	}
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := strings.TrimRight(o.BaseURL, "/") + "/api/chat"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := o.HTTPClient.Do(httpReq)
+	if err != nil {
+		return "", err
+	}
+	defer httpResp.Body.Close()
+
+	respBody, _ := io.ReadAll(httpResp.Body)
+	if httpResp.StatusCode >= 300 {
+		return "", errors.New("ollama API error: " + httpResp.Status + " - " + string(respBody))
+	}
+
+	var chatResp struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		return "", err
+	}
+
+	return chatResp.Message.Content, nil
+}
@@ -0,0 +1,119 @@
+package classifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+// OpenAIClassifier calls an OpenAI-compatible chat completions API such as
+// OpenAI itself or OpenRouter.
+type OpenAIClassifier struct {
+	BaseURL        string
+	APIKey         string
+	Model          string
+	PromptTemplate string
+	HTTPClient     *http.Client
+}
+
+func newOpenAIFromEnv() (*OpenAIClassifier, error) {
+	basePromptTemplate, err := promptTemplateFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	openRouterKey := os.Getenv("OPENROUTER_API_KEY")
+	openRouterBase := os.Getenv("OPENROUTER_BASE_URL")
+	model := os.Getenv("AI_MODEL")
+	if openRouterKey == "" {
+		return nil, errors.New("could not read OPENROUTER_API_KEY")
+	}
+	if openRouterBase == "" {
+		return nil, errors.New("could not read OPENROUTER_BASE_URL")
+	}
+	if model == "" {
+		// fallback if AI_MODEL not set
+		model = "gpt-4o-mini"
+	}
+
+	return &OpenAIClassifier{
+		BaseURL:        openRouterBase,
+		APIKey:         openRouterKey,
+		Model:          model,
+		PromptTemplate: basePromptTemplate,
+		HTTPClient:     &http.Client{Timeout: 20 * time.Second},
+	}, nil
+}
+
+func (o *OpenAIClassifier) Classify(ctx context.Context, review string, rankings []models.Ranking) (models.Ranking, error) {
+	return classifyStructured(ctx, o.chat, BuildPrompt(o.PromptTemplate, rankings), review, rankings)
+}
+
+func (o *OpenAIClassifier) chat(ctx context.Context, messages []chatMessage, schema map[string]interface{}) (string, error) {
+	reqBody := map[string]interface{}{
+		"model":       o.Model,
+		"messages":    messages,
+		"max_tokens":  800,
+		"temperature": 0,
+		"response_format": map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   "review_ranking",
+				"strict": true,
+				"schema": schema,
+			},
+		},
+	}
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := strings.TrimRight(o.BaseURL, "/") + "/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+o.APIKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := o.HTTPClient.Do(httpReq)
+	if err != nil {
+		return "", err
+	}
+	defer httpResp.Body.Close()
+
+	respBody, _ := io.ReadAll(httpResp.Body)
+	if httpResp.StatusCode >= 300 {
+		return "", errors.New("openai API error: " + httpResp.Status + " - " + string(respBody))
+	}
+
+	var orResp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Text string `json:"text"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &orResp); err != nil {
+		return "", err
+	}
+	if len(orResp.Choices) == 0 {
+		return "", errors.New("openai: no choices returned")
+	}
+
+	if orResp.Choices[0].Message.Content != "" {
+		return orResp.Choices[0].Message.Content, nil
+	}
+	return orResp.Choices[0].Text, nil
+}
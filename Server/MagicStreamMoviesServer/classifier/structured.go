@@ -0,0 +1,125 @@
+package classifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+// maxClassifyAttempts bounds how often a model is asked again after giving an
+// answer that is not one of the allowed rankings.
+const maxClassifyAttempts = 3
+
+// UnrecognizedRankingError is returned when a model keeps answering with
+// something that does not match any of the allowed rankings.
+type UnrecognizedRankingError struct {
+	Answer  string
+	Allowed []string
+}
+
+func (e *UnrecognizedRankingError) Error() string {
+	return fmt.Sprintf("model answered %q, expected one of %s", e.Answer, strings.Join(e.Allowed, ", "))
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatFunc sends the conversation to a model, asking it to follow schema, and
+// returns the raw text of its reply.
+type chatFunc func(ctx context.Context, messages []chatMessage, schema map[string]interface{}) (string, error)
+
+func rankingNames(rankings []models.Ranking) []string {
+	names := make([]string, 0, len(rankings))
+	for _, ranking := range rankings {
+		names = append(names, ranking.RankingName)
+	}
+	return names
+}
+
+// rankingSchema is the JSON schema the model's reply has to satisfy.
+func rankingSchema(rankings []models.Ranking) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"ranking": map[string]interface{}{
+				"type": "string",
+				"enum": rankingNames(rankings),
+			},
+		},
+		"required":             []string{"ranking"},
+		"additionalProperties": false,
+	}
+}
+
+// classifyStructured runs the prompt through chat, validates the structured
+// reply against rankings and, on a mismatch, tells the model what was wrong
+// and asks again.
+func classifyStructured(ctx context.Context, chat chatFunc, prompt string, review string, rankings []models.Ranking) (models.Ranking, error) {
+	names := rankingNames(rankings)
+	schema := rankingSchema(rankings)
+
+	messages := []chatMessage{
+		{
+			Role: "system",
+			Content: `Reply only with a JSON object of the form {"ranking": "<name>"} where <name> is exactly one of: ` +
+				strings.Join(names, ", ") + ".",
+		},
+		{Role: "user", Content: prompt + review},
+	}
+
+	var answer string
+	for attempt := 0; attempt < maxClassifyAttempts; attempt++ {
+		reply, err := chat(ctx, messages, schema)
+		if err != nil {
+			return models.Ranking{}, err
+		}
+
+		answer = parseRankingAnswer(reply)
+		if ranking, ok := findRanking(answer, rankings); ok {
+			return ranking, nil
+		}
+
+		messages = append(messages,
+			chatMessage{Role: "assistant", Content: reply},
+			chatMessage{Role: "user", Content: fmt.Sprintf(
+				`%q is not a valid ranking. Answer again with {"ranking": "<name>"} using exactly one of: %s.`,
+				answer, strings.Join(names, ", "),
+			)},
+		)
+	}
+
+	return models.Ranking{}, &UnrecognizedRankingError{Answer: answer, Allowed: names}
+}
+
+// parseRankingAnswer extracts the ranking name from a reply. Models that
+// ignore the schema sometimes wrap the JSON in a code fence or answer with the
+// bare name, so both are accepted.
+func parseRankingAnswer(reply string) string {
+	reply = strings.TrimSpace(reply)
+	reply = strings.TrimPrefix(reply, "```json")
+	reply = strings.Trim(reply, "`")
+	reply = strings.TrimSpace(reply)
+
+	var structured struct {
+		Ranking string `json:"ranking"`
+	}
+	if err := json.Unmarshal([]byte(reply), &structured); err == nil && structured.Ranking != "" {
+		return strings.TrimSpace(structured.Ranking)
+	}
+
+	return strings.Trim(reply, " \t\n\"'.!")
+}
+
+func findRanking(answer string, rankings []models.Ranking) (models.Ranking, bool) {
+	for _, ranking := range rankings {
+		if strings.EqualFold(ranking.RankingName, answer) {
+			return ranking, true
+		}
+	}
+	return models.Ranking{}, false
+}
@@ -0,0 +1,110 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scriptedChat answers with replies in order and records every conversation
+// it was sent.
+type scriptedChat struct {
+	replies []string
+	calls   [][]chatMessage
+}
+
+func (s *scriptedChat) chat(ctx context.Context, messages []chatMessage, schema map[string]interface{}) (string, error) {
+	s.calls = append(s.calls, append([]chatMessage(nil), messages...))
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return reply, nil
+}
+
+func TestClassifyStructured(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies []string
+		want    string
+		calls   int
+	}{
+		{"structured reply", []string{`{"ranking": "Good"}`}, "Good", 1},
+		{"case is ignored", []string{`{"ranking": "excellent"}`}, "Excellent", 1},
+		{"code fence", []string{"```json\n{\"ranking\": \"Bad\"}\n```"}, "Bad", 1},
+		{"bare name", []string{"Terrible."}, "Terrible", 1},
+		{"retry after an unknown ranking", []string{`{"ranking": "Meh"}`, `{"ranking": "Okay"}`}, "Okay", 2},
+		{"retry after prose", []string{"I would say it is fine", "sorry", `{"ranking": "Okay"}`}, "Okay", 3},
+	}
+
+	for _, tt := range tests {
+		chat := &scriptedChat{replies: tt.replies}
+		got, err := classifyStructured(context.Background(), chat.chat, "Rank this: ", "review", testRankings)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.RankingName != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got.RankingName, tt.want)
+		}
+		if len(chat.calls) != tt.calls {
+			t.Errorf("%s: model asked %d times, want %d", tt.name, len(chat.calls), tt.calls)
+		}
+	}
+}
+
+func TestClassifyStructuredTellsTheModelWhatWasWrong(t *testing.T) {
+	chat := &scriptedChat{replies: []string{`{"ranking": "Meh"}`, `{"ranking": "Okay"}`}}
+	if _, err := classifyStructured(context.Background(), chat.chat, "Rank this: ", "review", testRankings); err != nil {
+		t.Fatal(err)
+	}
+
+	retry := chat.calls[1]
+	if len(retry) != 4 {
+		t.Fatalf("retry sent %d messages, want the first two plus the rejected answer and a correction", len(retry))
+	}
+	if retry[2].Role != "assistant" || retry[2].Content != `{"ranking": "Meh"}` {
+		t.Errorf("retry did not include the rejected reply: %+v", retry[2])
+	}
+	if retry[3].Role != "user" || !strings.Contains(retry[3].Content, `"Meh" is not a valid ranking`) {
+		t.Errorf("retry did not explain the mistake: %+v", retry[3])
+	}
+}
+
+func TestClassifyStructuredGivesUp(t *testing.T) {
+	chat := &scriptedChat{replies: []string{"Meh", "Fine", `{"ranking": "Mid"}`, "unused"}}
+	_, err := classifyStructured(context.Background(), chat.chat, "Rank this: ", "review", testRankings)
+
+	var unrecognized *UnrecognizedRankingError
+	if !errors.As(err, &unrecognized) {
+		t.Fatalf("error = %v, want *UnrecognizedRankingError", err)
+	}
+	if len(chat.calls) != maxClassifyAttempts {
+		t.Errorf("model asked %d times, want %d", len(chat.calls), maxClassifyAttempts)
+	}
+	if unrecognized.Answer != "Mid" {
+		t.Errorf("Answer = %q, want the last answer %q", unrecognized.Answer, "Mid")
+	}
+	if want := rankingNames(testRankings); !reflect.DeepEqual(unrecognized.Allowed, want) {
+		t.Errorf("Allowed = %v, want %v", unrecognized.Allowed, want)
+	}
+	if msg := unrecognized.Error(); !strings.Contains(msg, `"Mid"`) || !strings.Contains(msg, "Excellent") {
+		t.Errorf("Error() = %q, want the answer and the allowed names", msg)
+	}
+}
+
+func TestClassifyStructuredReturnsChatErrors(t *testing.T) {
+	failure := errors.New("connection refused")
+	calls := 0
+	chat := func(context.Context, []chatMessage, map[string]interface{}) (string, error) {
+		calls++
+		return "", failure
+	}
+
+	if _, err := classifyStructured(context.Background(), chat, "Rank this: ", "review", testRankings); !errors.Is(err, failure) {
+		t.Errorf("error = %v, want the chat error", err)
+	}
+	if calls != 1 {
+		t.Errorf("model asked %d times after a transport error, want 1", calls)
+	}
+}
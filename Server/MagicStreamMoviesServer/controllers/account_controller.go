@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/loginguard"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/mailer"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// accountLink builds a link to a page of the web client that completes the
+// flow with the given token.
+func accountLink(path string, token string) string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = "http://localhost:5173"
+	}
+	return strings.TrimRight(base, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendAccountEmail delivers msg in the background, so that the response time
+// does not reveal whether an account exists for an address.
+func sendAccountEmail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := mailer.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send %q email to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// throttleAccountEmail counts a request for an account email to address and
+// answers 429 once the address or the client IP has asked for too many. The
+// limit applies whether or not the address has an account, so it reveals
+// nothing about it. It reports whether the handler may go on.
+func throttleAccountEmail(ctx context.Context, c *gin.Context, address string) bool {
+	decision, err := loginguard.Emails().Allow(ctx, address, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking email requests"})
+		return false
+	}
+	if !decision.Allowed {
+		retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Too many email requests, please try again later",
+			"retry_after": retryAfter,
+		})
+		return false
+	}
+	return true
+}
+
+// sendVerificationEmail mails a link confirming that the user owns email,
+// which is either their address or a pending change of it.
+func sendVerificationEmail(ctx context.Context, client *mongo.Client, user models.User, email string) error {
+	token, err := utils.IssueUserToken(ctx, client, user.UserID, models.UserTokenVerifyEmail, email, utils.VerifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	sendAccountEmail(mailer.Message{
+		To:      email,
+		Subject: "Confirm your MagicStream email address",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"Please confirm your email address by opening this link:\n\n" +
+			accountLink("/verify-email", token) + "\n\n" +
+			"The link is valid for 48 hours. If you did not request this, you can ignore this email.\n",
+	})
+	return nil
+}
+
+// VerifyEmail confirms an email address with a token from a verification
+// email. For a pending address change the new address replaces the old one.
+func VerifyEmail(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		token, err := utils.ConsumeUserToken(ctx, client, req.Token, models.UserTokenVerifyEmail)
+		if err != nil {
+			if errors.Is(err, utils.ErrInvalidUserToken) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Verification link is invalid or has expired"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking verification token"})
+			return
+		}
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+		// The token only counts for the address it was sent to, so a link for
+		// an address the user has since moved away from does nothing.
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"user_id": token.UserID, "email": token.Email},
+			bson.M{"$set": bson.M{"email_verified": true, "updated_at": time.Now()}},
+		)
+		if err == nil && result.MatchedCount == 0 {
+			result, err = userCollection.UpdateOne(ctx,
+				bson.M{"user_id": token.UserID, "pending_email": token.Email},
+				bson.M{
+					"$set":   bson.M{"email": token.Email, "email_verified": true, "updated_at": time.Now()},
+					"$unset": bson.M{"pending_email": ""},
+				},
+			)
+		}
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verifying email"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Verification link is invalid or has expired"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+	}
+}
+
+// ResendVerificationEmail sends a new verification link to an unverified
+// account. The response is the same whether or not the address is known.
+// Requests are limited per address and per client IP.
+func ResendVerificationEmail(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.EmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		if !throttleAccountEmail(ctx, c, req.Email) {
+			return
+		}
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"email": req.Email, "email_verified": false}).Decode(&user)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+		if err == nil {
+			if err := sendVerificationEmail(ctx, client, user, user.Email); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating verification token"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an unverified account, a new link has been sent"})
+	}
+}
+
+// ForgotPassword mails a password reset link. The response is the same
+// whether or not the address is known, so it cannot be used to find accounts.
+// It shares the per address and per IP limits of ResendVerificationEmail.
+func ForgotPassword(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.EmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		if !throttleAccountEmail(ctx, c, req.Email) {
+			return
+		}
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+		var user models.User
+		err := userCollection.FindOne(ctx, bson.M{"email": req.Email}).Decode(&user)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+		if err == nil {
+			token, err := utils.IssueUserToken(ctx, client, user.UserID, models.UserTokenPasswordReset, user.Email, utils.PasswordResetTokenTTL)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating reset token"})
+				return
+			}
+
+			sendAccountEmail(mailer.Message{
+				To:      user.Email,
+				Subject: "Reset your MagicStream password",
+				Body: "Hi " + user.FirstName + ",\n\n" +
+					"Someone asked to reset the password of your MagicStream account. Open this link to choose a new one:\n\n" +
+					accountLink("/reset-password", token) + "\n\n" +
+					"The link is valid for one hour and can be used once. If you did not ask for this, you can ignore this email.\n",
+			})
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "If an account exists for this address, a reset link has been sent"})
+	}
+}
+
+// ResetPassword sets a new password with a token from a reset email and signs
+// the user out everywhere. Receiving the email also proves the address, so
+// the account counts as verified afterwards.
+func ResetPassword(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.PasswordResetRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		hashedPassword, err := HashPassword(req.NewPassword)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		token, err := utils.ConsumeUserToken(ctx, client, req.Token, models.UserTokenPasswordReset)
+		if err != nil {
+			if errors.Is(err, utils.ErrInvalidUserToken) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Reset link is invalid or has expired"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking reset token"})
+			return
+		}
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"user_id": token.UserID, "email": token.Email},
+			bson.M{"$set": bson.M{"password": hashedPassword, "email_verified": true, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating password"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reset link is invalid or has expired"})
+			return
+		}
+
+		if err := utils.RevokeOtherSessions(ctx, client, token.UserID, bson.ObjectID{}, models.SessionRevokedPasswordReset); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Password updated but existing sessions could not be revoked"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password updated, please log in again"})
+	}
+}
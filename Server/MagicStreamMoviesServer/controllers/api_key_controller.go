@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const maxAPIKeysPerUser = 20
+
+// activeAPIKeys matches the user's keys that can still be used.
+func activeAPIKeys(userId string) bson.M {
+	return bson.M{
+		"user_id":    userId,
+		"revoked_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}
+}
+
+// CreateAPIKey issues a new API key for the caller. The key is only returned
+// in this response. Admin keys can only be created by admins.
+func CreateAPIKey(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+		role, err := utils.GetRoleFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Role not found in context"})
+			return
+		}
+
+		var req models.APIKeyCreate
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		if req.Scope == models.APIKeyScopeAdmin && role != "ADMIN" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can create admin API keys"})
+			return
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var apiKeyCollection *mongo.Collection = database.OpenCollection("api_keys", client)
+
+		count, err := apiKeyCollection.CountDocuments(ctx, activeAPIKeys(userId))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting API keys"})
+			return
+		}
+		if count >= maxAPIKeysPerUser {
+			c.JSON(http.StatusConflict, gin.H{"error": "Too many active API keys, revoke one first"})
+			return
+		}
+
+		key, prefix, err := utils.GenerateAPIKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating API key"})
+			return
+		}
+
+		apiKey := models.APIKey{
+			ID:        bson.NewObjectID(),
+			UserID:    userId,
+			Name:      req.Name,
+			Scope:     req.Scope,
+			KeyHash:   utils.HashToken(key),
+			Prefix:    prefix,
+			CreatedAt: time.Now(),
+			ExpiresAt: req.ExpiresAt,
+		}
+
+		if _, err := apiKeyCollection.InsertOne(ctx, apiKey); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating API key"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, models.CreatedAPIKey{APIKey: apiKey, Key: key})
+	}
+}
+
+// GetAPIKeys lists the caller's usable API keys, newest first.
+func GetAPIKeys(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var apiKeyCollection *mongo.Collection = database.OpenCollection("api_keys", client)
+
+		cursor, err := apiKeyCollection.Find(ctx, activeAPIKeys(userId),
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching API keys"})
+			return
+		}
+
+		apiKeys := []models.APIKey{}
+		if err := cursor.All(ctx, &apiKeys); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding API keys"})
+			return
+		}
+
+		c.JSON(http.StatusOK, apiKeys)
+	}
+}
+
+// RevokeAPIKey disables one of the caller's API keys immediately.
+func RevokeAPIKey(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		keyId, err := bson.ObjectIDFromHex(c.Param("key_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var apiKeyCollection *mongo.Collection = database.OpenCollection("api_keys", client)
+
+		result, err := apiKeyCollection.UpdateOne(ctx,
+			bson.M{"_id": keyId, "user_id": userId, "revoked_at": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking API key"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+	}
+}
@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	errGenreNotFound = errors.New("genre not found")
+	errUnknownGenre  = errors.New("unknown genre_id")
+	errGenreInUse    = errors.New("genre is still used by movies")
+)
+
+// genreEmbeddings lists where copies of a genre are embedded, as collection
+// name and array field.
+var genreEmbeddings = []struct {
+	collection string
+	field      string
+}{
+	{"movies", "genre"},
+	{"users", "favorite_genres"},
+}
+
+func CreateGenre(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var genre models.Genre
+		if err := c.ShouldBindJSON(&genre); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(genre); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var genreCollection *mongo.Collection = database.OpenCollection("genres", client)
+
+		if _, err := genreCollection.InsertOne(ctx, genre); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A genre with this id or name already exists"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating genre"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, genre)
+	}
+}
+
+// RenameGenre renames a genre and every copy of it embedded in movies and
+// users' favorite genres, in a single transaction.
+func RenameGenre(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		genreId, err := strconv.Atoi(c.Param("genre_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "genre_id must be an integer"})
+			return
+		}
+
+		var req struct {
+			GenreName string `json:"genre_name" validate:"required,min=2,max=100"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		err = database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			var genreCollection *mongo.Collection = database.OpenCollection("genres", client)
+
+			result, err := genreCollection.UpdateOne(ctx,
+				bson.M{"genre_id": genreId},
+				bson.M{"$set": bson.M{"genre_name": req.GenreName}},
+			)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return errGenreNotFound
+			}
+
+			for _, e := range genreEmbeddings {
+				collection := database.OpenCollection(e.collection, client)
+				_, err := collection.UpdateMany(ctx,
+					bson.M{e.field + ".genre_id": genreId},
+					bson.M{"$set": bson.M{e.field + ".$[g].genre_name": req.GenreName}},
+					options.UpdateMany().SetArrayFilters([]any{bson.M{"g.genre_id": genreId}}),
+				)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			respondGenreError(c, err, "Error renaming genre")
+			return
+		}
+
+		c.JSON(http.StatusOK, models.Genre{GenreId: genreId, GenreName: req.GenreName})
+	}
+}
+
+// MergeGenre folds one genre into another: every movie and user that had the
+// source genre gets the target instead (without duplicating it), and the
+// source genre is deleted.
+func MergeGenre(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sourceId, err := strconv.Atoi(c.Param("genre_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "genre_id must be an integer"})
+			return
+		}
+
+		var req struct {
+			IntoGenreId int `json:"into_genre_id" validate:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		if req.IntoGenreId == sourceId {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a genre into itself"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var target models.Genre
+		err = database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			var genreCollection *mongo.Collection = database.OpenCollection("genres", client)
+
+			if err := genreCollection.FindOne(ctx, bson.M{"genre_id": req.IntoGenreId}).Decode(&target); err != nil {
+				if errors.Is(err, mongo.ErrNoDocuments) {
+					return errGenreNotFound
+				}
+				return err
+			}
+
+			deleted, err := genreCollection.DeleteOne(ctx, bson.M{"genre_id": sourceId})
+			if err != nil {
+				return err
+			}
+			if deleted.DeletedCount == 0 {
+				return errGenreNotFound
+			}
+
+			for _, e := range genreEmbeddings {
+				collection := database.OpenCollection(e.collection, client)
+
+				// Documents without the target yet get the source replaced
+				// in place, keeping its position in the array.
+				_, err := collection.UpdateMany(ctx,
+					bson.M{"$and": bson.A{
+						bson.M{e.field + ".genre_id": sourceId},
+						bson.M{e.field + ".genre_id": bson.M{"$ne": req.IntoGenreId}},
+					}},
+					bson.M{"$set": bson.M{e.field + ".$[g]": target}},
+					options.UpdateMany().SetArrayFilters([]any{bson.M{"g.genre_id": sourceId}}),
+				)
+				if err != nil {
+					return err
+				}
+
+				// The rest already have the target, so the source just goes.
+				_, err = collection.UpdateMany(ctx,
+					bson.M{e.field + ".genre_id": sourceId},
+					bson.M{"$pull": bson.M{e.field: bson.M{"genre_id": sourceId}}},
+				)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			respondGenreError(c, err, "Error merging genres")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Genres merged", "genre": target})
+	}
+}
+
+// DeleteGenre removes a genre that no movie uses and drops it from users'
+// favorite genres. Writers claim the genres they save with claimGenres, so a
+// movie saved concurrently either commits first and blocks the delete, or is
+// retried after it and rejected as using an unknown genre.
+func DeleteGenre(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		genreId, err := strconv.Atoi(c.Param("genre_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "genre_id must be an integer"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var inUse int64
+		err = database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+			count, err := movieCollection.CountDocuments(ctx, bson.M{"genre.genre_id": genreId})
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				inUse = count
+				return errGenreInUse
+			}
+
+			var genreCollection *mongo.Collection = database.OpenCollection("genres", client)
+
+			result, err := genreCollection.DeleteOne(ctx, bson.M{"genre_id": genreId})
+			if err != nil {
+				return err
+			}
+			if result.DeletedCount == 0 {
+				return errGenreNotFound
+			}
+
+			var userCollection *mongo.Collection = database.OpenCollection("users", client)
+			_, err = userCollection.UpdateMany(ctx,
+				bson.M{"favorite_genres.genre_id": genreId},
+				bson.M{"$pull": bson.M{"favorite_genres": bson.M{"genre_id": genreId}}},
+			)
+			return err
+		})
+		if errors.Is(err, errGenreInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Genre is still used by movies; merge it into another genre instead", "movies": inUse})
+			return
+		}
+		if err != nil {
+			respondGenreError(c, err, "Error deleting genre")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Genre deleted"})
+	}
+}
+
+func respondGenreError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, errGenreNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Genre not found"})
+	case mongo.IsDuplicateKeyError(err):
+		c.JSON(http.StatusConflict, gin.H{"error": "A genre with this name already exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// loadGenres returns the genres collection keyed by genre_id.
+func loadGenres(ctx context.Context, client *mongo.Client) (map[int]models.Genre, error) {
+	return findGenres(ctx, client, bson.M{})
+}
+
+// claimGenres returns the genres with the given ids, keyed by genre_id, and
+// bumps their version. It must run in the transaction that saves the genres
+// into a movie or user: the version write makes that transaction conflict
+// with a concurrent DeleteGenre, MergeGenre or RenameGenre of the same genre,
+// so one of the two is retried after the other commits instead of both
+// committing against a snapshot the other has already changed.
+func claimGenres(ctx context.Context, client *mongo.Client, ids []int) (map[int]models.Genre, error) {
+	if len(ids) == 0 {
+		return map[int]models.Genre{}, nil
+	}
+
+	var genreCollection *mongo.Collection = database.OpenCollection("genres", client)
+
+	filter := bson.M{"genre_id": bson.M{"$in": ids}}
+	if _, err := genreCollection.UpdateMany(ctx, filter, bson.M{"$inc": bson.M{"version": 1}}); err != nil {
+		return nil, err
+	}
+	return findGenres(ctx, client, filter)
+}
+
+func findGenres(ctx context.Context, client *mongo.Client, filter bson.M) (map[int]models.Genre, error) {
+	var genreCollection *mongo.Collection = database.OpenCollection("genres", client)
+
+	cursor, err := genreCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var genres []models.Genre
+	if err := cursor.All(ctx, &genres); err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]models.Genre, len(genres))
+	for _, g := range genres {
+		known[g.GenreId] = g
+	}
+	return known, nil
+}
+
+// genreIds returns the distinct ids referenced by genres.
+func genreIds(genres []models.Genre) []int {
+	seen := map[int]bool{}
+	ids := make([]int, 0, len(genres))
+	for _, g := range genres {
+		if !seen[g.GenreId] {
+			seen[g.GenreId] = true
+			ids = append(ids, g.GenreId)
+		}
+	}
+	return ids
+}
+
+// canonicalGenres checks that every referenced genre exists and replaces the
+// caller-supplied names with the stored ones, so embedded copies never start
+// out stale.
+func canonicalGenres(genres []models.Genre, known map[int]models.Genre) ([]models.Genre, error) {
+	var unknown []string
+	canonical := make([]models.Genre, 0, len(genres))
+	for _, g := range genres {
+		stored, ok := known[g.GenreId]
+		if !ok {
+			unknown = append(unknown, strconv.Itoa(g.GenreId))
+			continue
+		}
+		canonical = append(canonical, stored)
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%w: %s", errUnknownGenre, strings.Join(unknown, ", "))
+	}
+	return canonical, nil
+}
+
+// resolveGenres is canonicalGenres for a single request. Like claimGenres it
+// must run inside the transaction that saves the result. Unknown genres are
+// reported as errUnknownGenre.
+func resolveGenres(ctx context.Context, client *mongo.Client, genres []models.Genre) ([]models.Genre, error) {
+	known, err := claimGenres(ctx, client, genreIds(genres))
+	if err != nil {
+		return nil, err
+	}
+	return canonicalGenres(genres, known)
+}
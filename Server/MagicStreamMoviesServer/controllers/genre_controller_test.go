@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// testClient connects to the MongoDB named by MONGODB_TEST_URI and points
+// OpenCollection at a throwaway database. It must be a replica set, since
+// the code under test relies on transactions.
+func testClient(t *testing.T) *mongo.Client {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	databaseName := fmt.Sprintf("magicstream_test_%d", time.Now().UnixNano())
+	t.Setenv("DATABASE_NAME", databaseName)
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_ = client.Database(databaseName).Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	return client
+}
+
+func TestDeleteGenreConflictsWithConcurrentAddMovie(t *testing.T) {
+	client := testClient(t)
+	ctx := context.Background()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/movies", AddMovie(client))
+	router.DELETE("/genres/:genre_id", DeleteGenre(client))
+
+	genreCollection := database.OpenCollection("genres", client)
+	movieCollection := database.OpenCollection("movies", client)
+
+	rankingCollection := database.OpenCollection("rankings", client)
+	if _, err := rankingCollection.InsertOne(ctx, bson.M{"ranking_value": 1, "ranking_name": "Excellent"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for round := 1; round <= 20; round++ {
+		if _, err := genreCollection.InsertOne(ctx, bson.M{"genre_id": round, "genre_name": fmt.Sprintf("Genre %d", round)}); err != nil {
+			t.Fatal(err)
+		}
+
+		body := fmt.Sprintf(`{
+			"imdb_id": "tt%07d",
+			"title": "Movie %d",
+			"poster_path": "https://example.com/poster.jpg",
+			"youtube_id": "dQw4w9WgXcQ",
+			"genre": [{"genre_id": %d, "genre_name": "Genre"}],
+			"ranking": {"ranking_value": 1, "ranking_name": "Excellent"}
+		}`, round, round, round)
+
+		insert, remove := httptest.NewRecorder(), httptest.NewRecorder()
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/movies", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(insert, req)
+		}()
+		go func() {
+			defer wg.Done()
+			router.ServeHTTP(remove, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/genres/%d", round), nil))
+		}()
+		wg.Wait()
+
+		if insert.Code == http.StatusCreated && remove.Code == http.StatusOK {
+			t.Fatalf("round %d: both the insert and the delete succeeded", round)
+		}
+
+		genres, err := genreCollection.CountDocuments(ctx, bson.M{"genre_id": round})
+		if err != nil {
+			t.Fatal(err)
+		}
+		movies, err := movieCollection.CountDocuments(ctx, bson.M{"genre.genre_id": round})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if genres == 0 && movies > 0 {
+			t.Fatalf("round %d: a movie was saved with a deleted genre (insert %d, delete %d)", round, insert.Code, remove.Code)
+		}
+	}
+}
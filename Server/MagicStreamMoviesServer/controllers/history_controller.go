@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func MarkMovieWatched(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		count, err := movieCollection.CountDocuments(ctx, bson.M{"imdb_id": movieId, "deleted_at": notDeleted})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movie"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		var historyCollection *mongo.Collection = database.OpenCollection("watch_history", client)
+
+		now := time.Now()
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+		var entry models.WatchHistoryEntry
+		markWatched := func() error {
+			return historyCollection.FindOneAndUpdate(ctx,
+				bson.M{"user_id": userId, "imdb_id": movieId},
+				bson.M{
+					"$set":         bson.M{"watched_at": now},
+					"$setOnInsert": bson.M{"first_watched_at": now},
+					"$inc":         bson.M{"watch_count": 1},
+				},
+				opts,
+			).Decode(&entry)
+		}
+
+		err = markWatched()
+		if mongo.IsDuplicateKeyError(err) {
+			// A concurrent first "watched" inserted the entry between our
+			// lookup and insert; retrying updates that entry instead.
+			err = markWatched()
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving watch history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, entry)
+	}
+}
+
+// GetWatchHistory lists the caller's watched movies, most recent first, with
+// the movie documents attached.
+func GetWatchHistory(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		page, pageSize, err := parsePagination(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var historyCollection *mongo.Collection = database.OpenCollection("watch_history", client)
+
+		total, err := historyCollection.CountDocuments(ctx, bson.M{"user_id": userId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting watch history"})
+			return
+		}
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"user_id": userId}}},
+			{{Key: "$sort", Value: bson.D{{Key: "watched_at", Value: -1}}}},
+			{{Key: "$skip", Value: (page - 1) * pageSize}},
+			{{Key: "$limit", Value: pageSize}},
+			{{Key: "$lookup", Value: bson.M{
+				"from":         "movies",
+				"localField":   "imdb_id",
+				"foreignField": "imdb_id",
+				"as":           "movie",
+			}}},
+			{{Key: "$unwind", Value: bson.M{"path": "$movie", "preserveNullAndEmptyArrays": true}}},
+		}
+
+		cursor, err := historyCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watch history"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		entries := []models.WatchHistoryEntry{}
+		if err := cursor.All(ctx, &entries); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding watch history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"history":   entries,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		})
+	}
+}
+
+func DeleteWatchHistoryEntry(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var historyCollection *mongo.Collection = database.OpenCollection("watch_history", client)
+
+		result, err := historyCollection.DeleteOne(ctx, bson.M{"user_id": userId, "imdb_id": c.Param("imdb_id")})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting watch history entry"})
+			return
+		}
+
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not in watch history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Watch history entry deleted"})
+	}
+}
+
+func ClearWatchHistory(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var historyCollection *mongo.Collection = database.OpenCollection("watch_history", client)
+
+		result, err := historyCollection.DeleteMany(ctx, bson.M{"user_id": userId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error clearing watch history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Watch history cleared", "deleted": result.DeletedCount})
+	}
+}
+
+// GetWatchedMovieIds returns the imdb_ids the user has watched.
+func GetWatchedMovieIds(userId string, client *mongo.Client, c *gin.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(c, 100*time.Second)
+	defer cancel()
+
+	var historyCollection *mongo.Collection = database.OpenCollection("watch_history", client)
+
+	opts := options.Find().SetProjection(bson.M{"imdb_id": 1, "_id": 0})
+
+	cursor, err := historyCollection.Find(ctx, bson.M{"user_id": userId}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []models.WatchHistoryEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	watched := make([]string, 0, len(entries))
+	for _, e := range entries {
+		watched = append(watched, e.ImdbID)
+	}
+	return watched, nil
+}
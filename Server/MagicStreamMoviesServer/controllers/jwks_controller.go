@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+)
+
+// GetJWKS publishes the public signing keys so that other services can verify
+// MagicStream tokens. Keys scheduled to sign later are already included, so a
+// verifier caching this response for its max-age still knows every key in use.
+func GetJWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=3600")
+		c.JSON(http.StatusOK, gin.H{"keys": utils.PublicJWKS()})
+	}
+}
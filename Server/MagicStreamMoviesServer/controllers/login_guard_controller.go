@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/loginguard"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	defaultLoginAuditLimit = 50
+	maxLoginAuditLimit     = 500
+)
+
+// UnlockUser lifts a lockout caused by failed logins and clears the account's
+// failed attempts. The unlock is recorded in the login audit.
+func UnlockUser(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": c.Param("user_id")}).Decode(&user); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+
+		wasLocked, err := loginguard.Default().Unlock(ctx, user.Email, adminId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error unlocking user"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User unlocked", "was_locked": wasLocked})
+	}
+}
+
+// GetLoginAudit lists the latest lockout and unlock events, newest first.
+func GetLoginAudit(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := defaultLoginAuditLimit
+		if v := c.Query("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+				return
+			}
+			limit = min(parsed, maxLoginAuditLimit)
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		events, err := loginguard.Default().AuditEvents(ctx, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching login audit"})
+			return
+		}
+
+		c.JSON(http.StatusOK, events)
+	}
+}
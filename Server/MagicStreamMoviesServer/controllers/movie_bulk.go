@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/ranking"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	bulkBatchSize     = 500
+	maxBulkLineLength = 1 << 20
+)
+
+type bulkRow struct {
+	index int
+	movie models.Movie
+}
+
+// ImportMovies accepts either a JSON array of movies or an NDJSON stream (one
+// movie per line, Content-Type application/x-ndjson). Every row is validated
+// with the same rules as AddMovie and reported individually, so one bad row
+// does not reject the rest of the import.
+func ImportMovies(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		knownGenres, err := loadGenres(ctx, client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error loading genres"})
+			return
+		}
+
+		var results []models.BulkMovieResult
+		var batch []bulkRow
+		seen := map[string]bool{}
+
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			rows, err := insertMovieBatch(ctx, client, batch)
+			results = append(results, rows...)
+			batch = batch[:0]
+			return err
+		}
+
+		row := 0
+		err = readBulkMovies(c.Request.Body, c.ContentType(), func(raw []byte) error {
+			row++
+
+			var movie models.Movie
+			if err := json.Unmarshal(raw, &movie); err != nil {
+				results = append(results, models.BulkMovieResult{Row: row, Status: "invalid", Error: "malformed JSON"})
+				return nil
+			}
+			movie.ResetServerFields()
+
+			// Normalize first so that the length rules apply to the names
+			// that are stored.
+			if err := normalizeMoviePeople(&movie); err != nil {
+				results = append(results, models.BulkMovieResult{Row: row, ImdbID: movie.ImdbID, Status: "invalid", Error: err.Error()})
+				return nil
+			}
+
+			if err := validate.Struct(movie); err != nil {
+				results = append(results, models.BulkMovieResult{Row: row, ImdbID: movie.ImdbID, Status: "invalid", Error: err.Error()})
+				return nil
+			}
+
+			genres, err := canonicalGenres(movie.Genre, knownGenres)
+			if err != nil {
+				results = append(results, models.BulkMovieResult{Row: row, ImdbID: movie.ImdbID, Status: "invalid", Error: err.Error()})
+				return nil
+			}
+			movie.Genre = genres
+
+			if seen[movie.ImdbID] {
+				results = append(results, models.BulkMovieResult{Row: row, ImdbID: movie.ImdbID, Status: "duplicate", Error: "imdb_id appears earlier in the import"})
+				return nil
+			}
+			seen[movie.ImdbID] = true
+
+			batch = append(batch, bulkRow{index: row, movie: movie})
+			if len(batch) >= bulkBatchSize {
+				return flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "results": results})
+			return
+		}
+
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Row < results[j].Row
+		})
+
+		summary := gin.H{}
+		for _, r := range results {
+			count, _ := summary[r.Status].(int)
+			summary[r.Status] = count + 1
+		}
+
+		c.JSON(http.StatusOK, gin.H{"summary": summary, "results": results})
+	}
+}
+
+// readBulkMovies calls fn with the raw JSON of every movie in body.
+func readBulkMovies(body io.Reader, contentType string, fn func([]byte) error) error {
+	if strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "jsonlines") {
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), maxBulkLineLength)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return errors.New("request body must be a JSON array or NDJSON stream")
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return errors.New("request body must be a JSON array or NDJSON stream")
+	}
+
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return errors.New("malformed JSON array")
+		}
+		if err := fn(raw); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return errors.New("malformed JSON array")
+	}
+	return nil
+}
+
+// insertMovieBatch inserts the batch in one transaction that also claims its
+// genres and rankings (see claimGenres), so a genre or ranking deleted during
+// the import is not saved into any movie. Rows whose movie already exists or
+// whose genres or ranking do not exist are reported individually and left
+// out, so the rest of the batch still goes in.
+func insertMovieBatch(ctx context.Context, client *mongo.Client, batch []bulkRow) ([]models.BulkMovieResult, error) {
+	var results []models.BulkMovieResult
+	insert := func() error {
+		return database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			results = make([]models.BulkMovieResult, 0, len(batch))
+
+			var ids, rankingValues []int
+			imdbIds := make([]string, 0, len(batch))
+			for _, r := range batch {
+				ids = append(ids, genreIds(r.movie.Genre)...)
+				rankingValues = append(rankingValues, r.movie.Ranking.RankingValue)
+				imdbIds = append(imdbIds, r.movie.ImdbID)
+			}
+
+			known, err := claimGenres(ctx, client, ids)
+			if err != nil {
+				return err
+			}
+			knownRankings, err := ranking.Claim(ctx, client, rankingValues)
+			if err != nil {
+				return err
+			}
+
+			var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+			// A duplicate key error would abort the whole transaction, so
+			// existing movies are picked out beforehand.
+			cursor, err := movieCollection.Find(ctx,
+				bson.M{"imdb_id": bson.M{"$in": imdbIds}},
+				options.Find().SetProjection(bson.M{"imdb_id": 1}),
+			)
+			if err != nil {
+				return err
+			}
+			var existing []models.Movie
+			if err := cursor.All(ctx, &existing); err != nil {
+				return err
+			}
+			exists := make(map[string]bool, len(existing))
+			for _, m := range existing {
+				exists[m.ImdbID] = true
+			}
+
+			docs := make([]interface{}, 0, len(batch))
+			for _, r := range batch {
+				result := models.BulkMovieResult{Row: r.index, ImdbID: r.movie.ImdbID, Status: "created"}
+				if exists[r.movie.ImdbID] {
+					result.Status, result.Error = "duplicate", "movie already exists"
+				} else if genres, err := canonicalGenres(r.movie.Genre, known); err != nil {
+					result.Status, result.Error = "invalid", err.Error()
+				} else if err := ranking.Check(r.movie.Ranking, knownRankings); err != nil {
+					result.Status, result.Error = "invalid", err.Error()
+				} else {
+					movie := r.movie
+					movie.Genre = genres
+					docs = append(docs, movie)
+				}
+				results = append(results, result)
+			}
+
+			if len(docs) == 0 {
+				return nil
+			}
+			_, err = movieCollection.InsertMany(ctx, docs)
+			return err
+		})
+	}
+
+	err := insert()
+	if mongo.IsDuplicateKeyError(err) {
+		// A movie of the batch was created since it was looked up; the
+		// retry reports it as a duplicate.
+		err = insert()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
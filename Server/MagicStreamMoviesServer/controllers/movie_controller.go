@@ -1,22 +1,21 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
-	"io"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/jobs"
 	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/ranking"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/recommend"
 	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -26,27 +25,69 @@ import (
 
 var validate = validator.New()
 
+// notDeleted matches movies that have not been soft deleted.
+var notDeleted = bson.M{"$exists": false}
+
 func GetMovies(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		query, err := parseMovieQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter, err := query.pageFilter()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
-		var movies []models.Movie
+		total, err := movieCollection.CountDocuments(ctx, query.Filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting movies"})
+			return
+		}
+
+		// Fetch one extra document to find out whether another page exists.
+		findOptions := options.Find().SetSort(query.sort()).SetLimit(query.PageSize + 1)
+		if query.After == nil {
+			findOptions.SetSkip((query.Page - 1) * query.PageSize)
+		}
 
-		cursor, err := movieCollection.Find(ctx, bson.M{})
+		cursor, err := movieCollection.Find(ctx, filter, findOptions)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movies from database"})
 			return
 		}
 		defer cursor.Close(ctx)
 
+		movies := []models.Movie{}
 		if err = cursor.All(ctx, &movies); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding movies"})
 			return
 		}
 
-		c.JSON(http.StatusOK, movies)
+		response := models.MovieListResponse{
+			Total:    total,
+			Page:     query.Page,
+			PageSize: query.PageSize,
+		}
+		if int64(len(movies)) > query.PageSize {
+			movies = movies[:query.PageSize]
+			response.NextCursor = query.cursorFor(movies[len(movies)-1])
+		}
+		response.Movies = movies
+
+		if err := markWatchlisted(c, client, response.Movies); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist"})
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
 	}
 }
 
@@ -64,12 +105,18 @@ func GetMovie(client *mongo.Client) gin.HandlerFunc {
 		var movie models.Movie
 		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
-		err := movieCollection.FindOne(ctx, bson.M{"imdb_id": movieID}).Decode(&movie)
+		err := movieCollection.FindOne(ctx, bson.M{"imdb_id": movieID, "deleted_at": notDeleted}).Decode(&movie)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
 			return
 		}
-		c.JSON(http.StatusOK, movie)
+
+		movies := []models.Movie{movie}
+		if err := markWatchlisted(c, client, movies); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist"})
+			return
+		}
+		c.JSON(http.StatusOK, movies[0])
 	}
 }
 
@@ -84,15 +131,43 @@ func AddMovie(client *mongo.Client) gin.HandlerFunc {
 			return
 		}
 
+		if err := normalizeMoviePeople(&movie); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
 		if err := validate.Struct(movie); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
 			return
 		}
+		movie.ResetServerFields()
 
-		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		var result *mongo.InsertOneResult
+		err := database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			genres, err := resolveGenres(ctx, client, movie.Genre)
+			if err != nil {
+				return err
+			}
+			movie.Genre = genres
+
+			if err := ranking.Resolve(ctx, client, movie.Ranking); err != nil {
+				return err
+			}
+
+			var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
-		result, err := movieCollection.InsertOne(ctx, movie)
+			result, err = movieCollection.InsertOne(ctx, movie)
+			return err
+		})
 		if err != nil {
+			if errors.Is(err, errUnknownGenre) || errors.Is(err, ranking.ErrUnknownRanking) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+				return
+			}
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Movie with this imdb_id already exists"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error inserting movie into database"})
 			return
 		}
@@ -100,64 +175,215 @@ func AddMovie(client *mongo.Client) gin.HandlerFunc {
 	}
 }
 
-func AdminReviewUpdate(client *mongo.Client) gin.HandlerFunc {
+// UpdateMovie replaces every editable field of a movie. Fields maintained by
+// the server, such as audience ratings, are left as they are.
+func UpdateMovie(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, err := utils.GetRoleFromContext(c)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Role not found in context"})
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
+		}
+
+		var movie models.Movie
+		if err := c.ShouldBindJSON(&movie); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if movie.ImdbID != "" && movie.ImdbID != movieId {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "imdb_id in body does not match the URL"})
 			return
 		}
+		movie.ImdbID = movieId
+
+		if err := normalizeMoviePeople(&movie); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(movie); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var updated models.Movie
+		err := database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			genres, err := resolveGenres(ctx, client, movie.Genre)
+			if err != nil {
+				return err
+			}
+			movie.Genre = genres
+
+			if err := ranking.Resolve(ctx, client, movie.Ranking); err != nil {
+				return err
+			}
 
-		if role != "ADMIN" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User must be part of the ADMIN role"})
+			var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+			update := bson.M{"$set": bson.M{
+				"title":        movie.Title,
+				"poster_path":  movie.PosterPath,
+				"youtube_id":   movie.YouTubeID,
+				"genre":        movie.Genre,
+				"admin_review": movie.AdminReview,
+				"ranking":      movie.Ranking,
+				"directors":    movie.Directors,
+				"cast":         movie.Cast,
+			}}
+			opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+			return movieCollection.FindOneAndUpdate(ctx, bson.M{"imdb_id": movieId, "deleted_at": notDeleted}, update, opts).Decode(&updated)
+		})
+		if err != nil {
+			if errors.Is(err, errUnknownGenre) || errors.Is(err, ranking.ErrUnknownRanking) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+				return
+			}
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
 			return
 		}
 
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+func PatchMovie(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		movieId := c.Param("imdb_id")
 		if movieId == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
 			return
 		}
 
-		var req struct {
-			AdminReview string `json:"admin_review"`
+		var patch models.MoviePatch
+		if err := c.ShouldBindJSON(&patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
 		}
-		var resp struct {
-			RankingName string `json:"ranking_name"`
-			AdminReview string `json:"admin_review"`
+
+		if patch.Directors != nil {
+			directors, err := normalizePeople("directors", *patch.Directors)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+				return
+			}
+			patch.Directors = &directors
+		}
+		if patch.Cast != nil {
+			cast, err := normalizePeople("cast", *patch.Cast)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+				return
+			}
+			patch.Cast = &cast
 		}
 
-		if err := c.ShouldBind(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		if err := validate.Struct(patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		set := bson.M{}
+		if patch.Title != nil {
+			set["title"] = *patch.Title
+		}
+		if patch.PosterPath != nil {
+			set["poster_path"] = *patch.PosterPath
+		}
+		if patch.YouTubeID != nil {
+			set["youtube_id"] = *patch.YouTubeID
+		}
+		if patch.Genre != nil {
+			set["genre"] = *patch.Genre
+		}
+		if patch.AdminReview != nil {
+			set["admin_review"] = *patch.AdminReview
+		}
+		if patch.Ranking != nil {
+			set["ranking"] = *patch.Ranking
+		}
+		if patch.Directors != nil {
+			set["directors"] = *patch.Directors
+		}
+		if patch.Cast != nil {
+			set["cast"] = *patch.Cast
+		}
+
+		if len(set) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
 			return
 		}
 
-		sentiment, rankVal, err := GetReviewRanking(req.AdminReview, client, c)
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var updated models.Movie
+		err := database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			if patch.Genre != nil {
+				genres, err := resolveGenres(ctx, client, *patch.Genre)
+				if err != nil {
+					return err
+				}
+				set["genre"] = genres
+			}
+			if patch.Ranking != nil {
+				if err := ranking.Resolve(ctx, client, *patch.Ranking); err != nil {
+					return err
+				}
+			}
+
+			var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+			opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+			return movieCollection.FindOneAndUpdate(ctx, bson.M{"imdb_id": movieId, "deleted_at": notDeleted}, bson.M{"$set": set}, opts).Decode(&updated)
+		})
 		if err != nil {
-			log.Println("GetReviewRanking error:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting review ranking", "details": err.Error()})
+			if errors.Is(err, errUnknownGenre) || errors.Is(err, ranking.ErrUnknownRanking) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+				return
+			}
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
 			return
 		}
 
-		filter := bson.M{"imdb_id": movieId}
+		c.JSON(http.StatusOK, updated)
+	}
+}
 
-		update := bson.M{
-			"$set": bson.M{
-				"admin_review": req.AdminReview,
-				"ranking": bson.M{
-					"ranking_value": rankVal,
-					"ranking_name":  sentiment,
-				},
-			},
+// DeleteMovie soft deletes a movie by stamping deleted_at. The document stays
+// in the collection so it can be brought back with RestoreMovie.
+func DeleteMovie(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
 		}
-		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 
 		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
-		result, err := movieCollection.UpdateOne(ctx, filter, update)
+		result, err := movieCollection.UpdateOne(ctx,
+			bson.M{"imdb_id": movieId, "deleted_at": notDeleted},
+			bson.M{"$set": bson.M{"deleted_at": time.Now()}},
+		)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting movie"})
 			return
 		}
 
@@ -166,145 +392,175 @@ func AdminReviewUpdate(client *mongo.Client) gin.HandlerFunc {
 			return
 		}
 
-		resp.RankingName = sentiment
-		resp.AdminReview = req.AdminReview
-
-		c.JSON(http.StatusOK, resp)
-
+		c.JSON(http.StatusOK, gin.H{"message": "Movie deleted"})
 	}
 }
 
-func GetReviewRanking(admin_review string, client *mongo.Client, c *gin.Context) (string, int, error) {
-	rankings, err := GetRankings(client, c)
-	if err != nil {
-		return "", 0, err
-	}
+func RestoreMovie(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
+		}
 
-	sentimentDelimited := ""
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
-	for _, ranking := range rankings {
-		if ranking.RankingValue != 999 {
-			sentimentDelimited = sentimentDelimited + ranking.RankingName + ","
+		result, err := movieCollection.UpdateOne(ctx,
+			bson.M{"imdb_id": movieId, "deleted_at": bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{"deleted_at": ""}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error restoring movie"})
+			return
 		}
-	}
-	sentimentDelimited = strings.Trim(sentimentDelimited, ",")
 
-	err = godotenv.Load(".env")
-	if err != nil {
-		log.Println("Warning: .env file not found")
-	}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Deleted movie not found"})
+			return
+		}
 
-	basePromptTemplate := os.Getenv("BASE_PROMPT_TEMPLATE")
-	if basePromptTemplate == "" {
-		return "", 0, errors.New("could not read BASE_PROMPT_TEMPLATE")
+		c.JSON(http.StatusOK, gin.H{"message": "Movie restored"})
 	}
+}
 
-	basePrompt := strings.Replace(basePromptTemplate, "{rankings}", sentimentDelimited, 1)
+// AdminReviewUpdate saves the review straight away and leaves ranking it to
+// the background workers; poll GetRankingStatus to see the outcome.
+func AdminReviewUpdate(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
+		}
 
-	openRouterKey := os.Getenv("OPENROUTER_API_KEY")
-	openRouterBase := os.Getenv("OPENROUTER_BASE_URL")
-	model := os.Getenv("AI_MODEL")
-	if openRouterKey == "" {
-		return "", 0, errors.New("could not read OPENROUTER_API_KEY")
-	}
-	if openRouterBase == "" {
-		return "", 0, errors.New("could not read OPENROUTER_BASE_URL")
-	}
-	if model == "" {
-		// fallback if AI_MODEL not set
-		model = "gpt-4o-mini"
-	}
+		var req struct {
+			AdminReview string `json:"admin_review"`
+		}
+		var resp struct {
+			AdminReview   string `json:"admin_review"`
+			RankingStatus string `json:"ranking_status"`
+			JobID         string `json:"job_id"`
+		}
 
-	// build OpenRouter chat request
-	reqBody := map[string]interface{}{
-		"model": model,
-		"messages": []map[string]string{
-			{"role": "user", "content": basePrompt + admin_review},
-		},
-		"max_tokens":  800,
-		"temperature": 0,
-	}
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
 
-	bodyBytes, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", 0, err
-	}
+		filter := bson.M{"imdb_id": movieId, "deleted_at": notDeleted}
 
-	endpoint := strings.TrimRight(openRouterBase, "/") + "/chat/completions"
-	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewReader(bodyBytes))
-	if err != nil {
-		return "", 0, err
-	}
-	httpReq.Header.Set("Authorization", "Bearer "+openRouterKey)
-	httpReq.Header.Set("Content-Type", "application/json")
+		update := bson.M{
+			"$set": bson.M{
+				"admin_review":   req.AdminReview,
+				"ranking_status": models.RankingStatusPending,
+			},
+		}
+		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
+		defer cancel()
 
-	clientCall := &http.Client{Timeout: 20 * time.Second}
-	httpResp, err := clientCall.Do(httpReq)
-	if err != nil {
-		return "", 0, err
-	}
-	defer httpResp.Body.Close()
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
-	respBody, _ := io.ReadAll(httpResp.Body)
-	if httpResp.StatusCode >= 300 {
-		return "", 0, errors.New("openrouter API error: " + httpResp.Status + " - " + string(respBody))
-	}
+		// The movie only becomes pending together with the job that ranks
+		// it, so it cannot be left pending with nothing scheduled.
+		var job models.Job
+		err := database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			result, err := movieCollection.UpdateOne(ctx, filter, update)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return mongo.ErrNoDocuments
+			}
 
-	var orResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-			Text string `json:"text"`
-		} `json:"choices"`
-	}
-	if err := json.Unmarshal(respBody, &orResp); err != nil {
-		return "", 0, err
-	}
-	if len(orResp.Choices) == 0 {
-		return "", 0, errors.New("openrouter: no choices returned")
-	}
+			job, err = jobs.EnqueueReviewRanking(ctx, client, movieId, req.AdminReview)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+				return
+			}
+			log.Println("AdminReviewUpdate error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
+			return
+		}
 
-	var responseText string
-	if orResp.Choices[0].Message.Content != "" {
-		responseText = strings.TrimSpace(orResp.Choices[0].Message.Content)
-	} else {
-		responseText = strings.TrimSpace(orResp.Choices[0].Text)
-	}
+		resp.AdminReview = req.AdminReview
+		resp.RankingStatus = models.RankingStatusPending
+		resp.JobID = job.ID.Hex()
 
-	rankVal := 0
+		c.JSON(http.StatusAccepted, resp)
 
-	for _, ranking := range rankings {
-		if ranking.RankingName == responseText {
-			rankVal = ranking.RankingValue
-			break
-		}
 	}
-	return responseText, rankVal, nil
-
 }
 
-func GetRankings(client *mongo.Client, c *gin.Context) ([]models.Ranking, error) {
-	var rankings []models.Ranking
+// GetRankingStatus reports the movie's ranking status and its latest ranking
+// job. When that job failed because the model never answered with a known
+// ranking, the status is "failed" and the response also carries the error
+// code and the allowed names.
+func GetRankingStatus(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
+		}
 
-	var ctx, cancel = context.WithTimeout(c, 100*time.Second)
-	defer cancel()
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
 
-	var rankingCollection *mongo.Collection = database.OpenCollection("rankings", client)
+		var movie models.Movie
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
 
-	cursor, err := rankingCollection.Find(ctx, bson.M{})
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
+		err := movieCollection.FindOne(ctx, bson.M{"imdb_id": movieId, "deleted_at": notDeleted}).Decode(&movie)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		job, err := jobs.LatestReviewRankingJob(ctx, client, movieId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching ranking job"})
+			return
+		}
+
+		if job != nil && job.Status == models.JobStatusFailed && job.ErrorCode == models.JobErrorUnrecognizedRanking {
+			c.JSON(http.StatusOK, gin.H{
+				"imdb_id":        movie.ImdbID,
+				"ranking_status": models.RankingStatusFailed,
+				"ranking":        movie.Ranking,
+				"error_code":     job.ErrorCode,
+				"allowed":        job.AllowedRankings,
+				"details":        job.LastError,
+				"job":            job,
+			})
+			return
+		}
 
-	if err := cursor.All(ctx, &rankings); err != nil {
-		return nil, err
+		c.JSON(http.StatusOK, gin.H{
+			"imdb_id":        movie.ImdbID,
+			"ranking_status": movie.RankingStatus,
+			"ranking":        movie.Ranking,
+			"job":            job,
+		})
 	}
+}
 
-	return rankings, nil
+func GetReviewRanking(admin_review string, client *mongo.Client, c *gin.Context) (string, int, error) {
+	result, err := ranking.RankReview(c, client, admin_review)
+	if err != nil {
+		return "", 0, err
+	}
+	return result.RankingName, result.RankingValue, nil
 }
 
+// GetRecommendedMovies returns the caller's recommendations from the
+// recommender assigned to them. With explain=true the response also carries
+// each movie's score and the signals that produced it.
 func GetRecommendedMovies(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId, err := utils.GetUserIdFromContext(c)
@@ -319,6 +575,24 @@ func GetRecommendedMovies(client *mongo.Client) gin.HandlerFunc {
 			return
 		}
 
+		watched, err := GetWatchedMovieIds(userId, client, c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watch history"})
+			return
+		}
+
+		ratings, err := GetUserRatings(userId, client, c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching ratings"})
+			return
+		}
+
+		favoriteDirectors, favoriteActors, err := GetUsersFavoritePeople(userId, client, c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching favorite people"})
+			return
+		}
+
 		err = godotenv.Load(".env")
 		if err != nil {
 			log.Println("Warning: .env file not found")
@@ -330,30 +604,59 @@ func GetRecommendedMovies(client *mongo.Client) gin.HandlerFunc {
 			recommendedMovieLimitVal, _ = strconv.ParseInt(recommendedMovieLimitValStr, 10, 64)
 		}
 
-		findOptions := options.Find()
-
-		findOptions.SetSort(bson.D{{Key: "ranking.ranking_value", Value: 1}}).SetLimit(recommendedMovieLimitVal)
-
-		filter := bson.M{"genre.genre_name": bson.M{"$in": favorite_genres}}
+		// An explicit strategy overrides the assigned one, which is how
+		// strategies are compared side by side for the same user.
+		var recommender recommend.Recommender
+		if strategy := c.Query("strategy"); strategy != "" {
+			recommender, err = recommend.New(strategy, client)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		} else {
+			recommender, err = recommend.ForUser(userId, client)
+		}
+		if err != nil {
+			log.Println("Recommender error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Recommender is misconfigured"})
+			return
+		}
 
 		ctx, cancel := context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 
-		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		profile := recommend.Profile{
+			UserID:            userId,
+			FavoriteGenres:    favorite_genres,
+			Watched:           watched,
+			Ratings:           ratings,
+			FavoriteDirectors: favoriteDirectors,
+			FavoriteActors:    favoriteActors,
+		}
 
-		cursor, err := movieCollection.Find(ctx, filter, findOptions)
+		recommendations, err := recommender.Recommend(ctx, profile, int(recommendedMovieLimitVal))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recommended movies"})
 			return
 		}
-		defer cursor.Close(ctx)
 
-		var recommendedMovies []models.Movie
-		if err := cursor.All(ctx, &recommendedMovies); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// The strategy is reported so that clients can attribute engagement to
+		// the variant a user was assigned.
+		c.Header("X-Recommender", recommender.Name())
+
+		if c.Query("explain") == "true" {
+			c.JSON(http.StatusOK, gin.H{
+				"strategy":        recommender.Name(),
+				"recommendations": recommendations,
+			})
 			return
 		}
 
+		recommendedMovies := make([]models.Movie, 0, len(recommendations))
+		for _, r := range recommendations {
+			recommendedMovies = append(recommendedMovies, r.Movie)
+		}
+
 		c.JSON(http.StatusOK, recommendedMovies)
 
 	}
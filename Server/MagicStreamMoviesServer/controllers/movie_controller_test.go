@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestGetRankingStatusReportsAnUnrecognizedRankingAsFailed(t *testing.T) {
+	client := testClient(t)
+	ctx := context.Background()
+
+	if _, err := database.OpenCollection("movies", client).InsertOne(ctx, bson.M{
+		"imdb_id":        "tt0000001",
+		"title":          "Movie",
+		"ranking_status": models.RankingStatusFailed,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.OpenCollection("jobs", client).InsertOne(ctx, models.Job{
+		Type:            models.JobTypeRankReview,
+		ImdbID:          "tt0000001",
+		Status:          models.JobStatusFailed,
+		LastError:       `model answered "Okay"`,
+		ErrorCode:       models.JobErrorUnrecognizedRanking,
+		AllowedRankings: []string{"Excellent", "Good"},
+		CreatedAt:       time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/movie/:imdb_id/ranking-status", GetRankingStatus(client))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/movie/tt0000001/ranking-status", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var body struct {
+		RankingStatus string   `json:"ranking_status"`
+		ErrorCode     string   `json:"error_code"`
+		Allowed       []string `json:"allowed"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.RankingStatus != models.RankingStatusFailed || body.ErrorCode != models.JobErrorUnrecognizedRanking || len(body.Allowed) != 2 {
+		t.Errorf("body = %s", w.Body)
+	}
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// movieSortFields maps the public sort keys accepted by GetMovies to the
+// document fields they sort on. "_id" orders by insertion time because
+// ObjectIDs start with their creation timestamp.
+var movieSortFields = map[string]string{
+	"title":                 "title",
+	"ranking":               "ranking.ranking_value",
+	"ranking.ranking_value": "ranking.ranking_value",
+	"created":               "_id",
+	"rating":                "rating_average",
+}
+
+type movieQuery struct {
+	Page     int64
+	PageSize int64
+	After    *movieCursor
+	SortKey  string
+	SortDir  int
+	Filter   bson.M
+}
+
+// movieCursor is the opaque position handed out as next_cursor. It stores the
+// sort key it was produced for so a cursor cannot be replayed against a
+// different ordering.
+type movieCursor struct {
+	Sort  string  `json:"s"`
+	Dir   int     `json:"d"`
+	ID    string  `json:"id"`
+	Title string  `json:"t,omitempty"`
+	Rank  int     `json:"r,omitempty"`
+	Score float64 `json:"a,omitempty"`
+}
+
+func parseMovieQuery(c *gin.Context) (*movieQuery, error) {
+	q := &movieQuery{
+		SortKey: "created",
+		SortDir: 1,
+		Filter:  bson.M{"deleted_at": notDeleted},
+	}
+
+	page, pageSize, err := parsePagination(c)
+	if err != nil {
+		return nil, err
+	}
+	q.Page, q.PageSize = page, pageSize
+
+	if v := c.Query("sort"); v != "" {
+		if _, ok := movieSortFields[v]; !ok {
+			return nil, errors.New("sort must be one of title, ranking, created, rating")
+		}
+		q.SortKey = v
+	}
+
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+		q.SortDir = 1
+	case "desc":
+		q.SortDir = -1
+	default:
+		return nil, errors.New("order must be asc or desc")
+	}
+
+	var genres []string
+	for _, g := range c.QueryArray("genre") {
+		for _, name := range strings.Split(g, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				genres = append(genres, name)
+			}
+		}
+	}
+	if len(genres) > 0 {
+		q.Filter["genre.genre_name"] = bson.M{"$in": genres}
+	}
+
+	rankRange := bson.M{}
+	if v := c.Query("ranking_min"); v != "" {
+		minRank, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.New("ranking_min must be an integer")
+		}
+		rankRange["$gte"] = minRank
+	}
+	if v := c.Query("ranking_max"); v != "" {
+		maxRank, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.New("ranking_max must be an integer")
+		}
+		rankRange["$lte"] = maxRank
+	}
+	if len(rankRange) > 0 {
+		q.Filter["ranking.ranking_value"] = rankRange
+	}
+
+	if v := strings.TrimSpace(c.Query("title")); v != "" {
+		q.Filter["title"] = bson.M{"$regex": "^" + regexp.QuoteMeta(v), "$options": "i"}
+	}
+
+	if v := c.Query("after"); v != "" {
+		cursor, err := decodeMovieCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != q.SortKey || cursor.Dir != q.SortDir {
+			return nil, errors.New("cursor does not match the requested sort order")
+		}
+		q.After = cursor
+	}
+
+	return q, nil
+}
+
+// parsePagination reads the page and page_size query parameters, defaulting
+// to the first page and capping the size at maxPageSize.
+func parsePagination(c *gin.Context) (int64, int64, error) {
+	var page, pageSize int64 = 1, defaultPageSize
+
+	if v := c.Query("page"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 1 {
+			return 0, 0, errors.New("page must be a positive integer")
+		}
+		page = parsed
+	}
+
+	if v := c.Query("page_size"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 1 {
+			return 0, 0, errors.New("page_size must be a positive integer")
+		}
+		pageSize = min(parsed, maxPageSize)
+	}
+
+	return page, pageSize, nil
+}
+
+// sort returns the sort document, using _id as a tie-breaker so that keyset
+// pagination is stable across movies sharing the same title or ranking.
+func (q *movieQuery) sort() bson.D {
+	field := movieSortFields[q.SortKey]
+	if field == "_id" {
+		return bson.D{{Key: "_id", Value: q.SortDir}}
+	}
+	return bson.D{{Key: field, Value: q.SortDir}, {Key: "_id", Value: q.SortDir}}
+}
+
+// pageFilter combines the user filters with the keyset condition derived from
+// the after cursor, if one was supplied.
+func (q *movieQuery) pageFilter() (bson.M, error) {
+	if q.After == nil {
+		return q.Filter, nil
+	}
+
+	id, err := bson.ObjectIDFromHex(q.After.ID)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	op := "$gt"
+	if q.SortDir < 0 {
+		op = "$lt"
+	}
+
+	var keyset bson.M
+	switch field := movieSortFields[q.SortKey]; field {
+	case "_id":
+		keyset = bson.M{"_id": bson.M{op: id}}
+	default:
+		var value interface{} = q.After.Title
+		switch field {
+		case "ranking.ranking_value":
+			value = q.After.Rank
+		case "rating_average":
+			value = q.After.Score
+		}
+		keyset = bson.M{"$or": bson.A{
+			bson.M{field: bson.M{op: value}},
+			bson.M{field: value, "_id": bson.M{op: id}},
+		}}
+	}
+
+	return bson.M{"$and": bson.A{q.Filter, keyset}}, nil
+}
+
+func (q *movieQuery) cursorFor(movie models.Movie) string {
+	cursor := movieCursor{
+		Sort: q.SortKey,
+		Dir:  q.SortDir,
+		ID:   movie.ID.Hex(),
+	}
+	switch movieSortFields[q.SortKey] {
+	case "title":
+		cursor.Title = movie.Title
+	case "ranking.ranking_value":
+		cursor.Rank = movie.Ranking.RankingValue
+	case "rating_average":
+		cursor.Score = movie.RatingAverage
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeMovieCursor(value string) (*movieCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var cursor movieCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &cursor, nil
+}
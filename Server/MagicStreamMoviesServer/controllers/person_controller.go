@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// peopleCollation compares person names case-insensitively. Queries on
+// directors and cast must use it to hit the matching indexes.
+var peopleCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// normalizePeople trims names, collapses inner whitespace and rejects names
+// that appear twice in the same list regardless of case.
+func normalizePeople(field string, names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		if name == "" {
+			return nil, fmt.Errorf("%s must not contain empty names", field)
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			return nil, fmt.Errorf("%s lists %q more than once", field, name)
+		}
+		seen[key] = true
+		normalized = append(normalized, name)
+	}
+	return normalized, nil
+}
+
+// normalizeMoviePeople normalizes the directors and cast of movie in place.
+func normalizeMoviePeople(movie *models.Movie) error {
+	directors, err := normalizePeople("directors", movie.Directors)
+	if err != nil {
+		return err
+	}
+	cast, err := normalizePeople("cast", movie.Cast)
+	if err != nil {
+		return err
+	}
+	movie.Directors, movie.Cast = directors, cast
+	return nil
+}
+
+// GetMoviesByPerson lists the movies a person directed or appears in. The
+// role query parameter narrows the search to director or cast.
+func GetMoviesByPerson(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := strings.Join(strings.Fields(c.Param("name")), " ")
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Person name is required"})
+			return
+		}
+
+		var filter bson.M
+		switch c.DefaultQuery("role", "any") {
+		case "director":
+			filter = bson.M{"directors": name}
+		case "cast":
+			filter = bson.M{"cast": name}
+		case "any":
+			filter = bson.M{"$or": bson.A{bson.M{"directors": name}, bson.M{"cast": name}}}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of director, cast, any"})
+			return
+		}
+		filter["deleted_at"] = notDeleted
+
+		page, pageSize, err := parsePagination(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		total, err := movieCollection.CountDocuments(ctx, filter, options.Count().SetCollation(peopleCollation))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting movies"})
+			return
+		}
+
+		findOptions := options.Find().
+			SetCollation(peopleCollation).
+			SetSort(bson.D{{Key: "ranking.ranking_value", Value: 1}, {Key: "_id", Value: 1}}).
+			SetSkip((page - 1) * pageSize).
+			SetLimit(pageSize)
+
+		cursor, err := movieCollection.Find(ctx, filter, findOptions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movies from database"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		movies := []models.Movie{}
+		if err := cursor.All(ctx, &movies); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding movies"})
+			return
+		}
+
+		if err := markWatchlisted(c, client, movies); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist"})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.MovieListResponse{
+			Movies:   movies,
+			Total:    total,
+			Page:     page,
+			PageSize: pageSize,
+		})
+	}
+}
+
+// UpdateFavoritePeople replaces the caller's favorite directors and actors.
+// A list left out of the body is not changed.
+func UpdateFavoritePeople(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		var req struct {
+			FavoriteDirectors *[]string `json:"favorite_directors" validate:"omitempty,max=50,dive,min=2,max=200"`
+			FavoriteActors    *[]string `json:"favorite_actors" validate:"omitempty,max=50,dive,min=2,max=200"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		// Normalize first so that the length rules apply to the names that
+		// are stored: " a " must fail min=2 rather than be saved as "a".
+		set := bson.M{}
+		if req.FavoriteDirectors != nil {
+			directors, err := normalizePeople("favorite_directors", *req.FavoriteDirectors)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+				return
+			}
+			req.FavoriteDirectors = &directors
+			set["favorite_directors"] = directors
+		}
+		if req.FavoriteActors != nil {
+			actors, err := normalizePeople("favorite_actors", *req.FavoriteActors)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+				return
+			}
+			req.FavoriteActors = &actors
+			set["favorite_actors"] = actors
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		if len(set) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+			return
+		}
+		set["updated_at"] = time.Now()
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+		opts := options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"favorite_directors": 1, "favorite_actors": 1})
+
+		var user models.User
+		err = userCollection.FindOneAndUpdate(ctx, bson.M{"user_id": userId}, bson.M{"$set": set}, opts).Decode(&user)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating favorites"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"favorite_directors": user.FavoriteDirectors,
+			"favorite_actors":    user.FavoriteActors,
+		})
+	}
+}
+
+// GetUsersFavoritePeople returns the user's favorite directors and actors.
+func GetUsersFavoritePeople(userId string, client *mongo.Client, c *gin.Context) ([]string, []string, error) {
+	ctx, cancel := context.WithTimeout(c, 100*time.Second)
+	defer cancel()
+
+	var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+	opts := options.FindOne().SetProjection(bson.M{"favorite_directors": 1, "favorite_actors": 1})
+
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}, opts).Decode(&user); err != nil {
+		return nil, nil, err
+	}
+	return user.FavoriteDirectors, user.FavoriteActors, nil
+}
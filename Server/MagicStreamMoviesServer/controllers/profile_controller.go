@@ -0,0 +1,444 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/loginguard"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func userResponse(user models.User) models.UserResponse {
+	return models.UserResponse{
+		UserID:            user.UserID,
+		FirstName:         user.FirstName,
+		LastName:          user.LastName,
+		Email:             user.Email,
+		Role:              user.Role,
+		EmailVerified:     user.EmailVerified,
+		PendingEmail:      user.PendingEmail,
+		FavoriteGenres:    user.FavoriteGenres,
+		FavoriteDirectors: user.FavoriteDirectors,
+		FavoriteActors:    user.FavoriteActors,
+	}
+}
+
+func GetProfile(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+
+		c.JSON(http.StatusOK, userResponse(user))
+	}
+}
+
+// UpdateProfile changes the caller's name or email. Fields left out of the
+// body are not changed. An email change must be confirmed with the current
+// password, and the new address only becomes pending: it replaces the
+// current one once confirmed through the verification link sent to it.
+func UpdateProfile(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		var patch models.UserPatch
+		if err := c.ShouldBindJSON(&patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+		set := bson.M{}
+		unset := bson.M{}
+		if patch.FirstName != nil {
+			set["first_name"] = *patch.FirstName
+		}
+		if patch.LastName != nil {
+			set["last_name"] = *patch.LastName
+		}
+		if patch.Email != nil {
+			var current models.User
+			if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&current); err != nil {
+				if errors.Is(err, mongo.ErrNoDocuments) {
+					c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+				return
+			}
+			if err := bcrypt.CompareHashAndPassword([]byte(current.Password), []byte(patch.CurrentPassword)); err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+				return
+			}
+
+			var owner models.User
+			err := userCollection.FindOne(ctx, bson.M{"email": *patch.Email}).Decode(&owner)
+			switch {
+			case err == nil && owner.UserID != userId:
+				c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+				return
+			case err == nil:
+				// Back to the current address: drop any pending change.
+				unset["pending_email"] = ""
+			case errors.Is(err, mongo.ErrNoDocuments):
+				set["pending_email"] = *patch.Email
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking email"})
+				return
+			}
+		}
+
+		if len(set) == 0 && len(unset) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+			return
+		}
+		set["updated_at"] = time.Now()
+
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+		var user models.User
+		err = userCollection.FindOneAndUpdate(ctx, bson.M{"user_id": userId}, update, opts).Decode(&user)
+		if err != nil {
+			if database.IsDuplicateKeyOn(err, database.UserEmailIndex) {
+				c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+				return
+			}
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating user"})
+			return
+		}
+
+		if _, pending := set["pending_email"]; pending {
+			if err := sendVerificationEmail(ctx, client, user, user.PendingEmail); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending verification email"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, userResponse(user))
+	}
+}
+
+// ChangePassword sets a new password after checking the current one.
+func ChangePassword(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		var req struct {
+			CurrentPassword string `json:"current_password" validate:"required"`
+			NewPassword     string `json:"new_password" validate:"required,min=6"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+
+		hashedPassword, err := HashPassword(req.NewPassword)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+
+		// Matching on the old hash keeps two concurrent changes from both
+		// succeeding with the same current password.
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"user_id": userId, "password": user.Password},
+			bson.M{"$set": bson.M{"password": hashedPassword, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating password"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Password was changed by another request"})
+			return
+		}
+
+		// Anyone who knew the old password may still hold a session, so
+		// every other device has to sign in again.
+		currentId, _ := utils.GetSessionIdFromContext(c)
+		keep, _ := bson.ObjectIDFromHex(currentId)
+		if err := utils.RevokeOtherSessions(ctx, client, userId, keep, models.SessionRevokedPasswordChange); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Password updated but other sessions could not be revoked"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password updated"})
+	}
+}
+
+// UpdateFavoriteGenres replaces the caller's favorite genres. Every genre
+// must exist in the genres collection.
+func UpdateFavoriteGenres(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		var req struct {
+			FavoriteGenres []models.Genre `json:"favorite_genres" validate:"required,dive"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var genres []models.Genre
+		err = database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			genres, err = resolveGenres(ctx, client, req.FavoriteGenres)
+			if err != nil {
+				return err
+			}
+
+			var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+			result, err := userCollection.UpdateOne(ctx,
+				bson.M{"user_id": userId},
+				bson.M{"$set": bson.M{"favorite_genres": genres, "updated_at": time.Now()}},
+			)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return mongo.ErrNoDocuments
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, errUnknownGenre) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+				return
+			}
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating favorite genres"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"favorite_genres": genres})
+	}
+}
+
+// userOwnedCollections hold documents that belong to a single user and are
+// removed with the account.
+var userOwnedCollections = []string{"user_reviews", "watchlist", "watch_history", "sessions", "api_keys", "user_tokens"}
+
+// DeleteAccount removes the caller's account together with their reviews and
+// the jobs labelling them, watchlist, watch history, sessions, API keys and
+// emailed tokens. Their ratings are removed as well and taken out of each
+// movie's audience score. Login attempts, lockout audit events and email
+// request counters are kept by address and are forgotten too. The watchlist
+// position counter lives on the user document and goes with it.
+func DeleteAccount(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		// Sessions are deleted with the account below, so deny their access
+		// tokens first; otherwise they keep working until they expire.
+		if err := utils.RevokeOtherSessions(ctx, client, userId, bson.ObjectID{}, models.SessionRevokedAccountDeleted); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking sessions"})
+			return
+		}
+		if token, err := utils.GetAccessTokenFromContext(c); err == nil {
+			sessionId, _ := utils.GetSessionIdFromContext(c)
+			if err := utils.RevokeToken(ctx, client, token, sessionId); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking token"})
+				return
+			}
+		}
+
+		err = database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+			var user models.User
+			if err := userCollection.FindOneAndDelete(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+				return err
+			}
+
+			if err := deleteUserRatings(ctx, client, userId); err != nil {
+				return err
+			}
+
+			if err := deleteUserReviewJobs(ctx, client, userId); err != nil {
+				return err
+			}
+
+			for _, name := range userOwnedCollections {
+				collection := database.OpenCollection(name, client)
+				if _, err := collection.DeleteMany(ctx, bson.M{"user_id": userId}); err != nil {
+					return err
+				}
+			}
+
+			// With the MongoDB store these writes join the transaction.
+			for _, email := range []string{user.Email, user.PendingEmail} {
+				if email == "" {
+					continue
+				}
+				if err := loginguard.Default().Forget(ctx, email); err != nil {
+					return err
+				}
+				if err := loginguard.Emails().Forget(ctx, email); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting account"})
+			return
+		}
+
+		clearAuthCookies(c)
+		c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+	}
+}
+
+// deleteUserReviewJobs removes the labelling jobs of the user's reviews,
+// which hold a copy of the review text.
+func deleteUserReviewJobs(ctx context.Context, client *mongo.Client, userId string) error {
+	var reviewCollection *mongo.Collection = database.OpenCollection("user_reviews", client)
+
+	cursor, err := reviewCollection.Find(ctx, bson.M{"user_id": userId}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+
+	var reviews []models.UserReview
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return err
+	}
+	if len(reviews) == 0 {
+		return nil
+	}
+
+	ids := make([]bson.ObjectID, 0, len(reviews))
+	for _, r := range reviews {
+		ids = append(ids, r.ID)
+	}
+
+	var jobCollection *mongo.Collection = database.OpenCollection("jobs", client)
+	_, err = jobCollection.DeleteMany(ctx, bson.M{"review_id": bson.M{"$in": ids}})
+	return err
+}
+
+// deleteUserRatings removes every rating by the user and subtracts each of
+// them from the rated movie's aggregates.
+func deleteUserRatings(ctx context.Context, client *mongo.Client, userId string) error {
+	var ratingCollection *mongo.Collection = database.OpenCollection("ratings", client)
+
+	cursor, err := ratingCollection.Find(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return err
+	}
+
+	var ratings []models.Rating
+	if err := cursor.All(ctx, &ratings); err != nil {
+		return err
+	}
+
+	for _, r := range ratings {
+		_, err := adjustMovieRating(ctx, client, r.ImdbID, -r.Stars, -1)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+	}
+
+	_, err = ratingCollection.DeleteMany(ctx, bson.M{"user_id": userId})
+	return err
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/ranking"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func GetRankings(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rankings, err := ranking.LoadRankings(c, client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching rankings"})
+			return
+		}
+		c.JSON(http.StatusOK, rankings)
+	}
+}
+
+func CreateRanking(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var def models.RankingDefinition
+		if err := c.ShouldBindJSON(&def); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(def); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var rankingCollection *mongo.Collection = database.OpenCollection("rankings", client)
+
+		if _, err := rankingCollection.InsertOne(ctx, def); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A ranking with this value or name already exists"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating ranking"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, def)
+	}
+}
+
+// rankingEmbeddings lists where copies of a ranking are embedded, as
+// collection name and field.
+var rankingEmbeddings = []struct {
+	collection string
+	field      string
+}{
+	{"movies", "ranking"},
+	{"user_reviews", "sentiment"},
+}
+
+var (
+	errRankingNotFound = errors.New("ranking not found")
+	errRankingInUse    = errors.New("ranking is still in use")
+)
+
+// UpdateRanking changes the name, color or selectable_by_ai flag of the
+// ranking with the given value. A new name is copied to every movie and user
+// review that embeds the ranking, in the same transaction. The value itself
+// is the ranking's identity and cannot change; create a new ranking instead.
+func UpdateRanking(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rankingValue, err := strconv.Atoi(c.Param("ranking_value"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ranking_value must be an integer"})
+			return
+		}
+
+		var def models.RankingDefinition
+		if err := c.ShouldBindJSON(&def); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if def.RankingValue != 0 && def.RankingValue != rankingValue {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ranking_value cannot be changed"})
+			return
+		}
+		def.RankingValue = rankingValue
+
+		if err := validate.Struct(def); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var updated models.RankingDefinition
+		err = database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			var rankingCollection *mongo.Collection = database.OpenCollection("rankings", client)
+
+			opts := options.FindOneAndReplace().SetReturnDocument(options.After)
+
+			err := rankingCollection.FindOneAndReplace(ctx, bson.M{"ranking_value": rankingValue}, def, opts).Decode(&updated)
+			if err != nil {
+				if errors.Is(err, mongo.ErrNoDocuments) {
+					return errRankingNotFound
+				}
+				return err
+			}
+
+			for _, e := range rankingEmbeddings {
+				collection := database.OpenCollection(e.collection, client)
+				_, err := collection.UpdateMany(ctx,
+					bson.M{e.field + ".ranking_value": rankingValue},
+					bson.M{"$set": bson.M{e.field + ".ranking_name": updated.RankingName}},
+				)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, errRankingNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Ranking not found"})
+				return
+			}
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A ranking with this name already exists"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating ranking"})
+			return
+		}
+
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// DeleteRanking removes a ranking that no movie or user review uses any more.
+// Writers claim the rankings they save with ranking.Claim, so one saved
+// concurrently either commits first and blocks the delete, or is retried
+// after it and rejected.
+func DeleteRanking(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rankingValue, err := strconv.Atoi(c.Param("ranking_value"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ranking_value must be an integer"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		inUse := gin.H{}
+		err = database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			inUse = gin.H{}
+			for _, e := range rankingEmbeddings {
+				collection := database.OpenCollection(e.collection, client)
+				count, err := collection.CountDocuments(ctx, bson.M{e.field + ".ranking_value": rankingValue})
+				if err != nil {
+					return err
+				}
+				if count > 0 {
+					inUse[e.collection] = count
+				}
+			}
+			if len(inUse) > 0 {
+				return errRankingInUse
+			}
+
+			var rankingCollection *mongo.Collection = database.OpenCollection("rankings", client)
+
+			result, err := rankingCollection.DeleteOne(ctx, bson.M{"ranking_value": rankingValue})
+			if err != nil {
+				return err
+			}
+			if result.DeletedCount == 0 {
+				return errRankingNotFound
+			}
+			return nil
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, errRankingInUse):
+				// Counts are keyed by collection, e.g. "movies": 3.
+				inUse["error"] = "Ranking is still used by movies or user reviews"
+				c.JSON(http.StatusConflict, inUse)
+			case errors.Is(err, errRankingNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Ranking not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting ranking"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Ranking deleted"})
+	}
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// RateMovie stores the caller's 1-5 star rating of a movie, replacing any
+// earlier rating, and adjusts the movie's rating aggregates by the difference.
+func RateMovie(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
+		}
+
+		var req struct {
+			Stars int `json:"stars" validate:"required,min=1,max=5"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		count, err := movieCollection.CountDocuments(ctx, bson.M{"imdb_id": movieId, "deleted_at": notDeleted})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movie"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		movie, err := saveRating(ctx, client, userId, movieId, req.Stars)
+		if mongo.IsDuplicateKeyError(err) {
+			// Two first ratings of the movie by this user raced on the
+			// unique index. Retrying updates the rating the other one stored.
+			movie, err = saveRating(ctx, client, userId, movieId, req.Stars)
+		}
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving rating"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"imdb_id":        movieId,
+			"stars":          req.Stars,
+			"rating_average": movie.RatingAverage,
+			"rating_count":   movie.RatingCount,
+		})
+	}
+}
+
+// saveRating upserts the user's rating and applies the difference to the
+// movie's aggregates in one transaction, so the two cannot drift apart.
+func saveRating(ctx context.Context, client *mongo.Client, userId string, movieId string, stars int) (models.Movie, error) {
+	var ratingCollection *mongo.Collection = database.OpenCollection("ratings", client)
+
+	var movie models.Movie
+	err := database.WithTransaction(ctx, client, func(ctx context.Context) error {
+		// Returning the document as it was before the upsert tells us
+		// whether this is a new rating or by how much it changed.
+		now := time.Now()
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+
+		var previous models.Rating
+		err := ratingCollection.FindOneAndUpdate(ctx,
+			bson.M{"user_id": userId, "imdb_id": movieId},
+			bson.M{
+				"$set":         bson.M{"stars": stars, "updated_at": now},
+				"$setOnInsert": bson.M{"created_at": now},
+			},
+			opts,
+		).Decode(&previous)
+
+		sumDelta, countDelta := stars, 1
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+		case err != nil:
+			return err
+		default:
+			sumDelta, countDelta = stars-previous.Stars, 0
+		}
+
+		movie, err = adjustMovieRating(ctx, client, movieId, sumDelta, countDelta)
+		return err
+	})
+	return movie, err
+}
+
+// adjustMovieRating applies a change to a movie's rating sum and count and
+// recomputes the average in the same update.
+func adjustMovieRating(ctx context.Context, client *mongo.Client, movieId string, sumDelta int, countDelta int) (models.Movie, error) {
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"rating_sum":   bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$rating_sum", 0}}, sumDelta}},
+			"rating_count": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$rating_count", 0}}, countDelta}},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"rating_average": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$rating_count", 0}},
+				bson.M{"$divide": bson.A{"$rating_sum", "$rating_count"}},
+				0,
+			}},
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var movie models.Movie
+	err := movieCollection.FindOneAndUpdate(ctx, bson.M{"imdb_id": movieId}, update, opts).Decode(&movie)
+	return movie, err
+}
+
+// GetUserRatings returns the stars the user gave, keyed by imdb_id.
+func GetUserRatings(userId string, client *mongo.Client, c *gin.Context) (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(c, 100*time.Second)
+	defer cancel()
+
+	var ratingCollection *mongo.Collection = database.OpenCollection("ratings", client)
+
+	opts := options.Find().SetProjection(bson.M{"imdb_id": 1, "stars": 1, "_id": 0})
+
+	cursor, err := ratingCollection.Find(ctx, bson.M{"user_id": userId}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var ratings []models.Rating
+	if err := cursor.All(ctx, &ratings); err != nil {
+		return nil, err
+	}
+
+	stars := make(map[string]int, len(ratings))
+	for _, r := range ratings {
+		stars[r.ImdbID] = r.Stars
+	}
+	return stars, nil
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/rerank"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// StartRerank re-ranks the whole catalog in the background and returns the run
+// record straight away; poll GetRerankRun for progress. Passing resume_run_id
+// continues an interrupted run instead of starting over, or answers 409 while
+// that run is still being processed. An empty body starts a run with the
+// defaults. Runs stop when serverCtx is cancelled on shutdown and can then be
+// resumed.
+func StartRerank(serverCtx context.Context, client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			DryRun      bool   `json:"dry_run"`
+			Concurrency int    `json:"concurrency" validate:"omitempty,min=1,max=32"`
+			ResumeRunID string `json:"resume_run_id"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var run *models.RerankRun
+		var err error
+		if req.ResumeRunID != "" {
+			run, err = rerank.Resume(ctx, client, req.ResumeRunID)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Rerank run not found"})
+				return
+			}
+			if errors.Is(err, rerank.ErrRunInProgress) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		} else {
+			run, err = rerank.Start(ctx, client, rerank.Options{DryRun: req.DryRun, Concurrency: req.Concurrency})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error starting rerank run"})
+				return
+			}
+		}
+
+		// The run outlives the request, so it must not use the request context.
+		go func(run models.RerankRun) {
+			if err := rerank.Run(serverCtx, client, &run, nil); err != nil {
+				log.Printf("rerank run %s stopped: %v", run.ID.Hex(), err)
+			}
+		}(*run)
+
+		c.JSON(http.StatusAccepted, run)
+	}
+}
+
+func GetRerankRun(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		run, err := rerank.Get(ctx, client, c.Param("run_id"))
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Rerank run not found"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, run)
+	}
+}
+
+// GetRerankRunChanges pages through the movies a run re-ranked or failed to
+// rank, in imdb_id order.
+func GetRerankRunChanges(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, pageSize, err := parsePagination(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		if _, err := rerank.Get(ctx, client, c.Param("run_id")); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Rerank run not found"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		changes, total, err := rerank.Changes(ctx, client, c.Param("run_id"), page, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching rerank changes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"changes":   changes,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStartRerankRejectsAnInvalidBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/admin/rerank", StartRerank(context.Background(), nil))
+
+	for _, body := range []string{`{"concurrency": 0`, `{"concurrency": 64}`, `[]`} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/rerank", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestStartRerankWithoutABodyUsesTheDefaults(t *testing.T) {
+	client := testClient(t)
+
+	serverCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/admin/rerank", StartRerank(serverCtx, client))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/rerank", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want %d: %s", w.Code, http.StatusAccepted, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), `"dry_run":false`) {
+		t.Errorf("run was not started with the defaults: %s", w.Body)
+	}
+}
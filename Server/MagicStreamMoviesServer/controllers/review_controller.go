@@ -0,0 +1,332 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/jobs"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type userReviewRequest struct {
+	Text string `json:"text" validate:"required,min=2,max=5000"`
+}
+
+// CreateUserReview posts the caller's review of a movie. Each user can review
+// a movie once; the review waits for moderation before it is listed and is
+// queued for sentiment labelling.
+func CreateUserReview(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
+		}
+
+		var req userReviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		count, err := movieCollection.CountDocuments(ctx, bson.M{"imdb_id": movieId, "deleted_at": notDeleted})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movie"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		now := time.Now()
+		review := models.UserReview{
+			UserID:          userId,
+			ImdbID:          movieId,
+			Text:            req.Text,
+			Status:          models.UserReviewStatusPending,
+			SentimentStatus: models.RankingStatusPending,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+
+		var reviewCollection *mongo.Collection = database.OpenCollection("user_reviews", client)
+
+		result, err := reviewCollection.InsertOne(ctx, review)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this movie"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving review"})
+			return
+		}
+		review.ID = result.InsertedID.(bson.ObjectID)
+
+		if _, err := jobs.EnqueueUserReviewLabel(ctx, client, review); err != nil {
+			log.Println("EnqueueUserReviewLabel error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Review saved but sentiment labelling could not be scheduled"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, review)
+	}
+}
+
+// UpdateUserReview lets the author change the text of their review. The
+// edited review goes back to moderation and is labelled again; reviews hidden
+// by a moderator can no longer be edited.
+func UpdateUserReview(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		reviewId, err := bson.ObjectIDFromHex(c.Param("review_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review id"})
+			return
+		}
+
+		var req userReviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var reviewCollection *mongo.Collection = database.OpenCollection("user_reviews", client)
+
+		filter := bson.M{
+			"_id":     reviewId,
+			"user_id": userId,
+			"status":  bson.M{"$ne": models.UserReviewStatusHidden},
+		}
+		update := bson.M{
+			"$set": bson.M{
+				"text":             req.Text,
+				"status":           models.UserReviewStatusPending,
+				"sentiment_status": models.RankingStatusPending,
+				"updated_at":       time.Now(),
+			},
+			"$unset": bson.M{"sentiment": "", "moderated_by": "", "moderated_at": ""},
+		}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+		var review models.UserReview
+		err = reviewCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&review)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Review not found or no longer editable"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating review"})
+			return
+		}
+
+		if _, err := jobs.EnqueueUserReviewLabel(ctx, client, review); err != nil {
+			log.Println("EnqueueUserReviewLabel error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Review saved but sentiment labelling could not be scheduled"})
+			return
+		}
+
+		c.JSON(http.StatusOK, review)
+	}
+}
+
+func DeleteUserReview(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		reviewId, err := bson.ObjectIDFromHex(c.Param("review_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var reviewCollection *mongo.Collection = database.OpenCollection("user_reviews", client)
+
+		result, err := reviewCollection.DeleteOne(ctx, bson.M{"_id": reviewId, "user_id": userId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting review"})
+			return
+		}
+
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Review deleted"})
+	}
+}
+
+// GetMovieReviews lists the approved reviews of a movie, newest first.
+func GetMovieReviews(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
+		}
+
+		listUserReviews(c, client,
+			bson.M{"imdb_id": movieId, "status": models.UserReviewStatusApproved},
+			bson.D{{Key: "created_at", Value: -1}},
+		)
+	}
+}
+
+// GetModerationQueue lists reviews in the given status (pending by default),
+// oldest first so moderators work through the backlog in order.
+func GetModerationQueue(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", models.UserReviewStatusPending)
+		switch status {
+		case models.UserReviewStatusPending, models.UserReviewStatusApproved, models.UserReviewStatusHidden:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of pending, approved, hidden"})
+			return
+		}
+
+		listUserReviews(c, client,
+			bson.M{"status": status},
+			bson.D{{Key: "created_at", Value: 1}},
+		)
+	}
+}
+
+func listUserReviews(c *gin.Context, client *mongo.Client, filter bson.M, sort bson.D) {
+	page, pageSize, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c, 100*time.Second)
+	defer cancel()
+
+	var reviewCollection *mongo.Collection = database.OpenCollection("user_reviews", client)
+
+	total, err := reviewCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting reviews"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(append(sort, bson.E{Key: "_id", Value: sort[0].Value})).
+		SetSkip((page - 1) * pageSize).
+		SetLimit(pageSize)
+
+	cursor, err := reviewCollection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reviews"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	reviews := []models.UserReview{}
+	if err := cursor.All(ctx, &reviews); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding reviews"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews":   reviews,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func ApproveUserReview(client *mongo.Client) gin.HandlerFunc {
+	return moderateUserReview(client, models.UserReviewStatusApproved)
+}
+
+func HideUserReview(client *mongo.Client) gin.HandlerFunc {
+	return moderateUserReview(client, models.UserReviewStatusHidden)
+}
+
+// moderateUserReview moves a review to status and records which admin did it.
+func moderateUserReview(client *mongo.Client, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		reviewId, err := bson.ObjectIDFromHex(c.Param("review_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var reviewCollection *mongo.Collection = database.OpenCollection("user_reviews", client)
+
+		update := bson.M{"$set": bson.M{
+			"status":       status,
+			"moderated_by": adminId,
+			"moderated_at": time.Now(),
+		}}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+		var review models.UserReview
+		err = reviewCollection.FindOneAndUpdate(ctx, bson.M{"_id": reviewId}, update, opts).Decode(&review)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error moderating review"})
+			return
+		}
+
+		c.JSON(http.StatusOK, review)
+	}
+}
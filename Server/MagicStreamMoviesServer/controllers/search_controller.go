@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	snippetRadius      = 60
+	minFuzzyTermLength = 4
+	// The fallback regexes grow with every term and, for fuzzy terms, with
+	// every character, so the query is bounded before they are built.
+	maxSearchQueryLength = 200
+	maxSearchTerms       = 8
+)
+
+type scoredMovie struct {
+	models.Movie `bson:",inline"`
+	Score        float64 `bson:"score"`
+}
+
+// SearchMovies ranks movies against the q parameter. Whole-word matches come
+// from the text index over title and admin_review; word prefixes and terms
+// with a single typo are matched with a regex fallback so that partially
+// typed or misspelled queries still return results.
+func SearchMovies(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query q is required"})
+			return
+		}
+		if utf8.RuneCountInString(query) > maxSearchQueryLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query must be at most " + strconv.Itoa(maxSearchQueryLength) + " characters"})
+			return
+		}
+
+		var limit int64 = defaultSearchLimit
+		if v := c.Query("limit"); v != "" {
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || parsed < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+				return
+			}
+			limit = min(parsed, maxSearchLimit)
+		}
+
+		terms := searchTerms(query)
+		if len(terms) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query must contain letters or digits"})
+			return
+		}
+		if len(terms) > maxSearchTerms {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query must have at most " + strconv.Itoa(maxSearchTerms) + " words"})
+			return
+		}
+
+		prefixPattern := termsPattern(terms, false)
+		fuzzyPattern := termsPattern(terms, true)
+
+		prefixRegex, err := regexp.Compile("(?i)" + prefixPattern)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is too complex"})
+			return
+		}
+		fuzzyRegex, err := regexp.Compile("(?i)" + fuzzyPattern)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is too complex"})
+			return
+		}
+		highlightRegex, err := regexp.Compile(`(?i)` + fuzzyPattern + `[\p{L}\p{N}]*`)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is too complex"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		textOptions := options.Find().
+			SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+			SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}).
+			SetLimit(limit)
+
+		cursor, err := movieCollection.Find(ctx, bson.M{"$text": bson.M{"$search": query}, "deleted_at": notDeleted}, textOptions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching movies"})
+			return
+		}
+
+		var matches []scoredMovie
+		if err := cursor.All(ctx, &matches); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding search results"})
+			return
+		}
+
+		if int64(len(matches)) < limit {
+			seen := bson.A{}
+			for _, m := range matches {
+				seen = append(seen, m.ImdbID)
+			}
+
+			fallbackFilter := bson.M{
+				"imdb_id":    bson.M{"$nin": seen},
+				"deleted_at": notDeleted,
+				"$or": bson.A{
+					bson.M{"title": bson.M{"$regex": fuzzyPattern, "$options": "i"}},
+					bson.M{"admin_review": bson.M{"$regex": prefixPattern, "$options": "i"}},
+				},
+			}
+
+			cursor, err := movieCollection.Find(ctx, fallbackFilter, options.Find().SetLimit(limit-int64(len(matches))))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching movies"})
+				return
+			}
+
+			var fallback []scoredMovie
+			if err := cursor.All(ctx, &fallback); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding search results"})
+				return
+			}
+
+			for i := range fallback {
+				fallback[i].Score = fallbackScore(fallback[i].Movie, prefixRegex, fuzzyRegex)
+			}
+			matches = append(matches, fallback...)
+		}
+
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Score > matches[j].Score
+		})
+
+		results := make([]models.MovieSearchResult, 0, len(matches))
+		for _, m := range matches {
+			results = append(results, models.MovieSearchResult{
+				Movie:      m.Movie,
+				Score:      m.Score,
+				Highlights: highlightMovie(m.Movie, highlightRegex),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"query": query, "results": results})
+	}
+}
+
+func searchTerms(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	seen := map[string]bool{}
+	var terms []string
+	for _, f := range fields {
+		if !seen[f] {
+			seen[f] = true
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+// termsPattern builds a regex matching the start of a word against any of the
+// terms. With fuzzy set, longer terms also match with one substituted,
+// inserted or deleted character.
+func termsPattern(terms []string, fuzzy bool) string {
+	var alternatives []string
+	for _, term := range terms {
+		alternatives = append(alternatives, regexp.QuoteMeta(term))
+
+		runes := []rune(term)
+		if !fuzzy || len(runes) < minFuzzyTermLength {
+			continue
+		}
+		for i := range runes {
+			head := regexp.QuoteMeta(string(runes[:i]))
+			alternatives = append(alternatives,
+				head+"."+regexp.QuoteMeta(string(runes[i+1:])),
+				head+regexp.QuoteMeta(string(runes[i+1:])),
+				head+"."+regexp.QuoteMeta(string(runes[i:])),
+			)
+		}
+	}
+	return `\b(?:` + strings.Join(alternatives, "|") + `)`
+}
+
+func fallbackScore(movie models.Movie, prefix, fuzzy *regexp.Regexp) float64 {
+	score := 0.0
+	score += float64(len(prefix.FindAllStringIndex(movie.Title, -1)))
+	if score == 0 {
+		score += 0.5 * float64(len(fuzzy.FindAllStringIndex(movie.Title, -1)))
+	}
+	score += 0.2 * float64(len(prefix.FindAllStringIndex(movie.AdminReview, -1)))
+	return score
+}
+
+func highlightMovie(movie models.Movie, re *regexp.Regexp) []models.SearchHighlight {
+	highlights := []models.SearchHighlight{}
+
+	if re.MatchString(movie.Title) {
+		highlights = append(highlights, models.SearchHighlight{
+			Field:   "title",
+			Snippet: markMatches(movie.Title, re),
+		})
+	}
+
+	if loc := re.FindStringIndex(movie.AdminReview); loc != nil {
+		start, end := loc[0]-snippetRadius, loc[1]+snippetRadius
+		prefix, suffix := "…", "…"
+		if start <= 0 {
+			start, prefix = 0, ""
+		}
+		if end >= len(movie.AdminReview) {
+			end, suffix = len(movie.AdminReview), ""
+		}
+		for start > 0 && !utf8.RuneStart(movie.AdminReview[start]) {
+			start--
+		}
+		for end < len(movie.AdminReview) && !utf8.RuneStart(movie.AdminReview[end]) {
+			end++
+		}
+
+		highlights = append(highlights, models.SearchHighlight{
+			Field:   "admin_review",
+			Snippet: prefix + markMatches(movie.AdminReview[start:end], re) + suffix,
+		})
+	}
+
+	return highlights
+}
+
+// markMatches HTML-escapes text and wraps every match of re in <em> tags.
+func markMatches(text string, re *regexp.Regexp) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:loc[0]]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</em>")
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTermsPatternCompilesForTheLargestQueries(t *testing.T) {
+	queries := []string{
+		strings.Repeat("a", maxSearchQueryLength),
+		strings.TrimSpace(strings.Repeat("abcdefghijklmnopqrstuvw ", maxSearchTerms)),
+	}
+
+	for _, query := range queries {
+		terms := searchTerms(query)
+		if len(terms) > maxSearchTerms {
+			t.Fatalf("query has %d terms, more than the %d allowed", len(terms), maxSearchTerms)
+		}
+		for _, fuzzy := range []bool{false, true} {
+			if _, err := regexp.Compile("(?i)" + termsPattern(terms, fuzzy) + `[\p{L}\p{N}]*`); err != nil {
+				t.Errorf("pattern for a %d character query (fuzzy=%v) does not compile: %v", len(query), fuzzy, err)
+			}
+		}
+	}
+}
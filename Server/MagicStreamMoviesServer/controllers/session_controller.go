@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// GetSessions lists the devices the caller is signed in on. The session the
+// request was made from is flagged as current.
+func GetSessions(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+		currentId, _ := utils.GetSessionIdFromContext(c)
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		sessions, err := utils.ActiveSessions(ctx, client, userId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching sessions"})
+			return
+		}
+
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID.Hex() == currentId
+		}
+
+		c.JSON(http.StatusOK, sessions)
+	}
+}
+
+// RevokeSession signs the caller out of one of their sessions. Its refresh
+// token stops working immediately.
+func RevokeSession(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		sessionId, err := bson.ObjectIDFromHex(c.Param("session_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		revoked, err := utils.RevokeSession(ctx, client, userId, sessionId, models.SessionRevokedByUser)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking session"})
+			return
+		}
+		if !revoked {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+	}
+}
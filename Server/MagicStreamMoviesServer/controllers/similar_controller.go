@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/similarity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const defaultSimilarLimit = 10
+
+// GetSimilarMovies returns the movies most often watched or liked by the same
+// users as the given one, best match first. The neighbours come from the
+// offline similarity job, so a new movie has none until the job runs again.
+func GetSimilarMovies(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
+		}
+
+		limit := defaultSimilarLimit
+		if v := c.Query("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+				return
+			}
+			limit = parsed
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		err := movieCollection.FindOne(ctx, bson.M{"imdb_id": movieId, "deleted_at": notDeleted}).Err()
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movie"})
+			return
+		}
+
+		neighbours, err := similarity.Neighbours(ctx, client, []string{movieId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching similar movies"})
+			return
+		}
+
+		list := neighbours[movieId]
+		ids := make([]string, 0, len(list))
+		for _, n := range list {
+			ids = append(ids, n.ImdbID)
+		}
+
+		movies := []models.Movie{}
+		if len(ids) > 0 {
+			cursor, err := movieCollection.Find(ctx, bson.M{"imdb_id": bson.M{"$in": ids}, "deleted_at": notDeleted})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching similar movies"})
+				return
+			}
+			if err := cursor.All(ctx, &movies); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding similar movies"})
+				return
+			}
+		}
+
+		if err := markWatchlisted(c, client, movies); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist"})
+			return
+		}
+
+		byId := make(map[string]models.Movie, len(movies))
+		for _, m := range movies {
+			byId[m.ImdbID] = m
+		}
+
+		// Keep the neighbour order; deleted movies simply drop out.
+		similar := make([]models.SimilarMovie, 0, limit)
+		for _, n := range list {
+			movie, ok := byId[n.ImdbID]
+			if !ok {
+				continue
+			}
+			similar = append(similar, models.SimilarMovie{Movie: movie, Score: n.Score, CoUsers: n.CoUsers})
+			if len(similar) == limit {
+				break
+			}
+		}
+
+		c.JSON(http.StatusOK, similar)
+	}
+}
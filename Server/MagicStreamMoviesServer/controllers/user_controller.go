@@ -2,14 +2,18 @@ package controllers
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"log"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/loginguard"
 	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
 	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -39,6 +43,16 @@ func RegisterUser(client *mongo.Client) gin.HandlerFunc {
 			return
 		}
 
+		var err error
+		if user.FavoriteDirectors, err = normalizePeople("favorite_directors", user.FavoriteDirectors); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if user.FavoriteActors, err = normalizePeople("favorite_actors", user.FavoriteActors); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		hashedPassword, err := HashPassword(user.Password)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
@@ -51,30 +65,81 @@ func RegisterUser(client *mongo.Client) gin.HandlerFunc {
 
 		var userCollection *mongo.Collection = database.OpenCollection("users", client)
 
-		count, err := userCollection.CountDocuments(ctx, bson.M{"email": user.Email})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking for existing user"})
-			return
-		}
-		if count > 0 {
-			c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
-			return
-		}
 		user.UserID = bson.NewObjectID().Hex()
 		user.CreatedAt = time.Now()
 		user.UpdatedAt = time.Now()
 		user.Role = "USER"
-
-		result, insertErr := userCollection.InsertOne(ctx, user)
+		user.EmailVerified = false
+		user.PendingEmail = ""
+
+		var result *mongo.InsertOneResult
+		insertErr := database.WithTransaction(ctx, client, func(ctx context.Context) error {
+			favoriteGenres, err := resolveGenres(ctx, client, user.FavoriteGenres)
+			if err != nil {
+				return err
+			}
+			user.FavoriteGenres = favoriteGenres
+
+			result, err = userCollection.InsertOne(ctx, user)
+			return err
+		})
 		if insertErr != nil {
+			if errors.Is(insertErr, errUnknownGenre) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": insertErr.Error()})
+				return
+			}
+			if database.IsDuplicateKeyOn(insertErr, database.UserEmailIndex) {
+				c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user"})
 			return
 		}
+
+		// The account exists either way; a failed email can be sent again
+		// through /verify-email/resend.
+		if err := sendVerificationEmail(ctx, client, user, user.Email); err != nil {
+			log.Printf("Failed to create verification token for user %s: %v", user.UserID, err)
+		}
+
 		c.JSON(http.StatusCreated, result)
 
 	}
 }
 
+var errInvalidCredentials = errors.New("invalid email or password")
+
+// authenticateLogin checks the credentials under the login guard. The attempt
+// is reserved before the password is checked and settled afterwards. Unknown
+// emails count as failures like wrong passwords, so that lockouts do not
+// reveal which accounts exist. When the guard turns the attempt away, the
+// returned decision is not allowed and the error is nil.
+func authenticateLogin(ctx context.Context, guard *loginguard.Guard, findUser func(context.Context, string) (models.User, error), email string, password string, ip string) (models.User, loginguard.Decision, error) {
+	attempt, decision, err := guard.Begin(ctx, email, ip)
+	if err != nil || !decision.Allowed {
+		return models.User{}, decision, err
+	}
+
+	user, err := findUser(ctx, email)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return models.User{}, decision, err
+	}
+	if err == nil {
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	}
+	if err != nil {
+		if err := guard.Fail(ctx, attempt); err != nil {
+			log.Printf("Failed to record failed login: %v", err)
+		}
+		return models.User{}, decision, errInvalidCredentials
+	}
+
+	if err := guard.Succeed(ctx, attempt); err != nil {
+		log.Printf("Failed to reset failed logins: %v", err)
+	}
+	return user, decision, nil
+}
+
 func LoginUser(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var userLogin models.UserLogin
@@ -86,55 +151,52 @@ func LoginUser(client *mongo.Client) gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(c, 100*time.Second)
 		defer cancel()
 
-		var foundUser models.User
 		var userCollection *mongo.Collection = database.OpenCollection("users", client)
+		findUser := func(ctx context.Context, email string) (models.User, error) {
+			var user models.User
+			err := userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+			return user, err
+		}
 
-		err := userCollection.FindOne(ctx, bson.M{"email": userLogin.Email}).Decode(&foundUser)
+		foundUser, decision, err := authenticateLogin(ctx, loginguard.Default(), findUser, userLogin.Email, userLogin.Password, c.ClientIP())
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+			if errors.Is(err, errInvalidCredentials) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking credentials"})
+			return
+		}
+		if !decision.Allowed {
+			retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many failed login attempts, please try again later",
+				"reason":      decision.Reason,
+				"retry_after": retryAfter,
+			})
 			return
 		}
 
-		err = bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(userLogin.Password))
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		if !foundUser.EmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address before logging in"})
 			return
 		}
 
-		token, refreshToken, err := utils.GenerateAllTokens(foundUser.Email, foundUser.FirstName, foundUser.LastName, foundUser.Role, foundUser.UserID)
+		sessionId := bson.NewObjectID()
+
+		token, refreshToken, err := utils.GenerateAllTokens(foundUser.Email, foundUser.FirstName, foundUser.LastName, foundUser.Role, foundUser.UserID, sessionId.Hex())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
 			return
 		}
-		err = utils.UpdateAllTokens(token, refreshToken, foundUser.UserID, client, c)
+		err = utils.CreateSession(sessionId, foundUser.UserID, token, refreshToken, time.Now().Add(utils.RefreshTokenTTL), client, c)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating tokens"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating session"})
 			return
 		}
 
-		domain := os.Getenv("COOKIE_DOMAIN")
-
-		http.SetCookie(c.Writer, &http.Cookie{
-			Name: "access_token",
-			Value: token,
-			Path: "/",
-			Domain: domain,
-			MaxAge: 86400,
-			Secure: true,
-			HttpOnly: true,
-			SameSite: http.SameSiteNoneMode,
-		})
-		
-		http.SetCookie(c.Writer, &http.Cookie{
-			Name: "refresh_token",
-			Value: refreshToken,
-			Path: "/",
-			Domain: domain,
-			MaxAge: 604800,
-			Secure: true,
-			HttpOnly: true,
-			SameSite: http.SameSiteNoneMode,
-		})
+		setAuthCookies(c, token, refreshToken)
 
 		c.JSON(http.StatusOK, models.UserResponse{
 			UserID:         foundUser.UserID,
@@ -142,80 +204,110 @@ func LoginUser(client *mongo.Client) gin.HandlerFunc {
 			LastName:       foundUser.LastName,
 			Email:          foundUser.Email,
 			Role:           foundUser.Role,
+			EmailVerified:  foundUser.EmailVerified,
 			// Token:          token,
 			// RefreshToken:   refreshToken,
-			FavoriteGenres: foundUser.FavoriteGenres,
+			FavoriteGenres:    foundUser.FavoriteGenres,
+			FavoriteDirectors: foundUser.FavoriteDirectors,
+			FavoriteActors:    foundUser.FavoriteActors,
 		})
 	}
 }
 
+// LogoutHandler ends the caller's session. The session is identified from the
+// access token cookie, or from the refresh token when the access token has
+// already expired, so a request can only ever log out its own session. The
+// session's access tokens are denylisted until they expire. Cookies are
+// cleared even when neither token is valid any more.
 func LogoutHandler(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Clear the access_token cookie
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
 
-		var UserLogout struct {
-			UserId string `json:"user_id"`
+		var claims *utils.SignedDetails
+		if token, err := utils.GetAccessToken(c); err == nil {
+			claims, _ = utils.ValidateToken(token)
+		}
+		if claims == nil {
+			if token, err := c.Cookie("refresh_token"); err == nil {
+				claims, _ = utils.ValidateRefreshToken(token)
+			}
 		}
 
-		err := c.ShouldBindJSON(&UserLogout)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
-			return
+		if claims != nil {
+			if sessionId, err := bson.ObjectIDFromHex(claims.SessionId); err == nil {
+				if _, err := utils.RevokeSession(ctx, client, claims.UserId, sessionId, models.SessionRevokedLogout); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking session"})
+					return
+				}
+			}
+			if claims.ID != "" && claims.ExpiresAt != nil {
+				// Tokens of the session are already denylisted; this covers an
+				// access token that was never tracked on it.
+				token := models.IssuedToken{ID: claims.ID, ExpiresAt: claims.ExpiresAt.Time}
+				if err := utils.RevokeToken(ctx, client, token, claims.SessionId); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking token"})
+					return
+				}
+			}
 		}
 
-		fmt.Println("User ID from Logout request:", UserLogout.UserId)
+		clearAuthCookies(c)
 
-		err = utils.UpdateAllTokens(UserLogout.UserId, "", "", client, c) // Clear tokens in the database
-		// Optionally, you can also remove the user session from the database if needed
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	}
+}
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error logging out"})
-			return
-		}
-		// c.SetCookie(
-		// 	"access_token",
-		// 	"",
-		// 	-1, // MaxAge negative → delete immediately
-		// 	"/",
-		// 	"localhost", // Adjust to your domain
-		// 	true,        // Use true in production with HTTPS
-		// 	true,        // HttpOnly
-		// )
-		http.SetCookie(c.Writer, &http.Cookie{
-			Name:  "access_token",
-			Value: "",
-			Path:  "/",
-			// Domain:   "localhost",
-			MaxAge:   -1,
-			Secure:   true,
-			HttpOnly: true,
-			SameSite: http.SameSiteNoneMode,
-		})
+// setAuthCookies stores a token pair for COOKIE_DOMAIN. Login and refresh
+// must set them the same way, or a refresh on another domain never replaces
+// the login cookies and the next refresh reuses the rotated token.
+func setAuthCookies(c *gin.Context, token, refreshToken string) {
+	domain := os.Getenv("COOKIE_DOMAIN")
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "access_token",
+		Value:    token,
+		Path:     "/",
+		Domain:   domain,
+		MaxAge:   86400,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Path:     "/",
+		Domain:   domain,
+		MaxAge:   604800,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
 
-		// // Clear the refresh_token cookie
-		// c.SetCookie(
-		// 	"refresh_token",
-		// 	"",
-		// 	-1,
-		// 	"/",
-		// 	"localhost",
-		// 	true,
-		// 	true,
-		// )
+func clearAuthCookies(c *gin.Context) {
+	domain := os.Getenv("COOKIE_DOMAIN")
+
+	for _, name := range []string{"access_token", "refresh_token"} {
 		http.SetCookie(c.Writer, &http.Cookie{
-			Name:     "refresh_token",
+			Name:     name,
 			Value:    "",
 			Path:     "/",
+			Domain:   domain,
 			MaxAge:   -1,
 			Secure:   true,
 			HttpOnly: true,
 			SameSite: http.SameSiteNoneMode,
 		})
-
-		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 	}
 }
 
+// RefreshTokenHandler rotates the refresh token and issues a new access
+// token. Clients should refresh one request at a time; when several tabs
+// race with the same cookie, the losers get 409 and should carry on with the
+// cookies set by the winner instead of logging out.
 func RefreshTokenHandler(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(c, 100*time.Second)
@@ -224,14 +316,12 @@ func RefreshTokenHandler(client *mongo.Client) gin.HandlerFunc {
 		refreshToken, err := c.Cookie("refresh_token")
 
 		if err != nil {
-			fmt.Println("error", err.Error())
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unable to retrieve refresh token from cookie"})
 			return
 		}
 
 		claim, err := utils.ValidateRefreshToken(refreshToken)
 		if err != nil || claim == nil {
-			fmt.Println("error", err.Error())
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
 			return
 		}
@@ -246,15 +336,30 @@ func RefreshTokenHandler(client *mongo.Client) gin.HandlerFunc {
 			return
 		}
 
-		newToken, newRefreshToken, _ := utils.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.Role, user.UserID)
-		err = utils.UpdateAllTokens(user.UserID, newToken, newRefreshToken, client, c)
+		newToken, newRefreshToken, err := utils.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.Role, user.UserID, claim.SessionId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
+			return
+		}
+
+		err = utils.RotateSession(claim.SessionId, refreshToken, newToken, newRefreshToken, time.Now().Add(utils.RefreshTokenTTL), client, c)
 		if err != nil {
+			if errors.Is(err, utils.ErrRefreshTokenSuperseded) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Tokens were already refreshed by another request"})
+				return
+			}
+			if errors.Is(err, utils.ErrRefreshTokenReused) {
+				log.Printf("Refresh token reuse detected for user %s, session %s revoked", user.UserID, claim.SessionId)
+			}
+			if errors.Is(err, utils.ErrRefreshTokenReused) || errors.Is(err, utils.ErrSessionNotFound) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session is no longer valid, please log in again"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating tokens"})
 			return
 		}
 
-		c.SetCookie("access_token", newToken, 86400, "/", "localhost", true, true)          // expires in 24 hours
-		c.SetCookie("refresh_token", newRefreshToken, 604800, "/", "localhost", true, true) //expires in 1 week
+		setAuthCookies(c, newToken, newRefreshToken)
 
 		c.JSON(http.StatusOK, gin.H{"message": "Tokens refreshed"})
 	}
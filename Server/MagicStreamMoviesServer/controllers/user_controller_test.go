@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/loginguard"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticateLoginLocksOutUnknownEmails(t *testing.T) {
+	guard := loginguard.New(loginguard.NewMemoryStore(), loginguard.Policy{
+		Window:  loginguard.DefaultPolicy.Window,
+		Lockout: loginguard.DefaultPolicy.Lockout,
+		Account: loginguard.Limit{FreeFailures: 100, MaxFailures: 3},
+		IP:      loginguard.Limit{FreeFailures: 100, MaxFailures: 100},
+	})
+	noUser := func(context.Context, string) (models.User, error) {
+		return models.User{}, mongo.ErrNoDocuments
+	}
+
+	for i := range 3 {
+		_, decision, err := authenticateLogin(context.Background(), guard, noUser, "nobody@example.com", "secret1", "203.0.113.7")
+		if !decision.Allowed || !errors.Is(err, errInvalidCredentials) {
+			t.Fatalf("attempt %d = %+v, %v; want an allowed attempt with invalid credentials", i+1, decision, err)
+		}
+	}
+
+	_, decision, err := authenticateLogin(context.Background(), guard, noUser, "nobody@example.com", "secret1", "203.0.113.7")
+	if err != nil || decision.Allowed || decision.Reason != "account_locked" {
+		t.Fatalf("attempt after the limit = %+v, %v; want account_locked", decision, err)
+	}
+}
+
+func TestAuthenticateLoginCountsWrongPasswords(t *testing.T) {
+	guard := loginguard.New(loginguard.NewMemoryStore(), loginguard.Policy{
+		Window:  loginguard.DefaultPolicy.Window,
+		Lockout: loginguard.DefaultPolicy.Lockout,
+		Account: loginguard.Limit{FreeFailures: 100, MaxFailures: 2},
+		IP:      loginguard.Limit{FreeFailures: 100, MaxFailures: 100},
+	})
+	hash, err := bcrypt.GenerateFromPassword([]byte("right-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findUser := func(context.Context, string) (models.User, error) {
+		return models.User{UserID: "u1", Password: string(hash)}, nil
+	}
+
+	user, decision, err := authenticateLogin(context.Background(), guard, findUser, "jane@example.com", "right-password", "203.0.113.7")
+	if err != nil || !decision.Allowed || user.UserID != "u1" {
+		t.Fatalf("correct password = %+v, %+v, %v; want the user", user, decision, err)
+	}
+
+	for range 2 {
+		if _, _, err := authenticateLogin(context.Background(), guard, findUser, "jane@example.com", "wrong", "203.0.113.7"); !errors.Is(err, errInvalidCredentials) {
+			t.Fatalf("wrong password error = %v, want errInvalidCredentials", err)
+		}
+	}
+
+	// Even the right password is not checked while the account is locked.
+	_, decision, err = authenticateLogin(context.Background(), guard, findUser, "jane@example.com", "right-password", "203.0.113.7")
+	if err != nil || decision.Allowed {
+		t.Fatalf("correct password during lockout = %+v, %v; want it turned away", decision, err)
+	}
+}
+
+func TestSetAuthCookiesUsesTheConfiguredDomain(t *testing.T) {
+	t.Setenv("COOKIE_DOMAIN", "magicstream.example")
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	setAuthCookies(c, "access", "refresh")
+
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies, want 2", len(cookies))
+	}
+	for _, cookie := range cookies {
+		if cookie.Domain != "magicstream.example" || cookie.SameSite != http.SameSiteNoneMode || !cookie.Secure || !cookie.HttpOnly {
+			t.Errorf("cookie %s = %+v, want Secure, HttpOnly, SameSite=None on magicstream.example", cookie.Name, cookie)
+		}
+	}
+}
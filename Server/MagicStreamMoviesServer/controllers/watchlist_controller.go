@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// AddToWatchlist appends a movie to the end of the caller's watchlist. Adding
+// a movie that is already on it leaves its position unchanged.
+func AddToWatchlist(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		movieId := c.Param("imdb_id")
+		if movieId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "movieId required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		count, err := movieCollection.CountDocuments(ctx, bson.M{"imdb_id": movieId, "deleted_at": notDeleted})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movie"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		position, err := nextWatchlistPosition(ctx, client, userId)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist"})
+			return
+		}
+
+		var watchlistCollection *mongo.Collection = database.OpenCollection("watchlist", client)
+
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+		var entry models.WatchlistEntry
+		err = watchlistCollection.FindOneAndUpdate(ctx,
+			bson.M{"user_id": userId, "imdb_id": movieId},
+			bson.M{"$setOnInsert": bson.M{"position": position, "added_at": time.Now()}},
+			opts,
+		).Decode(&entry)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving watchlist"})
+			return
+		}
+
+		c.JSON(http.StatusOK, entry)
+	}
+}
+
+// nextWatchlistPosition hands out the user's next watchlist position from a
+// counter on the user document. Incrementing it is atomic, so concurrent adds
+// never share a position. Users from before the counter start after the last
+// position already on their watchlist.
+func nextWatchlistPosition(ctx context.Context, client *mongo.Client, userId string) (int, error) {
+	var watchlistCollection *mongo.Collection = database.OpenCollection("watchlist", client)
+
+	var last models.WatchlistEntry
+	seed := -1
+	err := watchlistCollection.FindOne(ctx,
+		bson.M{"user_id": userId},
+		options.FindOne().SetSort(bson.D{{Key: "position", Value: -1}}),
+	).Decode(&last)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+	case err != nil:
+		return 0, err
+	default:
+		seed = last.Position
+	}
+
+	var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"watchlist_position": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$watchlist_position", seed}}, 1}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"watchlist_position": 1})
+
+	var counter struct {
+		Position int `bson:"watchlist_position"`
+	}
+	if err := userCollection.FindOneAndUpdate(ctx, bson.M{"user_id": userId}, update, opts).Decode(&counter); err != nil {
+		return 0, err
+	}
+	return counter.Position, nil
+}
+
+func RemoveFromWatchlist(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var watchlistCollection *mongo.Collection = database.OpenCollection("watchlist", client)
+
+		result, err := watchlistCollection.DeleteOne(ctx, bson.M{"user_id": userId, "imdb_id": c.Param("imdb_id")})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating watchlist"})
+			return
+		}
+
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not in watchlist"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Movie removed from watchlist"})
+	}
+}
+
+// GetWatchlist returns the movies on the caller's watchlist in the order the
+// user arranged them. Movies that have since been deleted are left out.
+func GetWatchlist(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var watchlistCollection *mongo.Collection = database.OpenCollection("watchlist", client)
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"user_id": userId}}},
+			{{Key: "$sort", Value: bson.D{{Key: "position", Value: 1}, {Key: "added_at", Value: 1}}}},
+			{{Key: "$lookup", Value: bson.M{
+				"from":         "movies",
+				"localField":   "imdb_id",
+				"foreignField": "imdb_id",
+				"as":           "movie",
+			}}},
+			{{Key: "$unwind", Value: "$movie"}},
+			{{Key: "$match", Value: bson.M{"movie.deleted_at": notDeleted}}},
+			{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$movie"}}},
+		}
+
+		cursor, err := watchlistCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		movies := []models.Movie{}
+		if err := cursor.All(ctx, &movies); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding watchlist"})
+			return
+		}
+
+		inWatchlist := true
+		for i := range movies {
+			movies[i].InWatchlist = &inWatchlist
+		}
+
+		c.JSON(http.StatusOK, movies)
+	}
+}
+
+// ReorderWatchlist rearranges the caller's watchlist. The body must list
+// every imdb_id currently on the watchlist exactly once, in the new order.
+func ReorderWatchlist(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetUserIdFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User Id not found in context"})
+			return
+		}
+
+		var req struct {
+			ImdbIDs []string `json:"imdb_ids" validate:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 100*time.Second)
+		defer cancel()
+
+		var watchlistCollection *mongo.Collection = database.OpenCollection("watchlist", client)
+
+		cursor, err := watchlistCollection.Find(ctx, bson.M{"user_id": userId}, options.Find().SetProjection(bson.M{"imdb_id": 1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist"})
+			return
+		}
+
+		var entries []models.WatchlistEntry
+		if err := cursor.All(ctx, &entries); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding watchlist"})
+			return
+		}
+
+		current := map[string]bool{}
+		for _, e := range entries {
+			current[e.ImdbID] = true
+		}
+
+		seen := map[string]bool{}
+		for _, id := range req.ImdbIDs {
+			if !current[id] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Movie " + id + " is not in the watchlist"})
+				return
+			}
+			if seen[id] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Movie " + id + " is listed more than once"})
+				return
+			}
+			seen[id] = true
+		}
+		if len(seen) != len(current) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "imdb_ids must list every movie in the watchlist"})
+			return
+		}
+
+		if len(req.ImdbIDs) > 0 {
+			writes := make([]mongo.WriteModel, 0, len(req.ImdbIDs))
+			for i, id := range req.ImdbIDs {
+				writes = append(writes, mongo.NewUpdateOneModel().
+					SetFilter(bson.M{"user_id": userId, "imdb_id": id}).
+					SetUpdate(bson.M{"$set": bson.M{"position": i}}))
+			}
+
+			if _, err := watchlistCollection.BulkWrite(ctx, writes); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reordering watchlist"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"imdb_ids": req.ImdbIDs})
+	}
+}
+
+// markWatchlisted sets InWatchlist on each movie when the request comes from
+// an authenticated user. Anonymous callers get no flag at all.
+func markWatchlisted(c *gin.Context, client *mongo.Client, movies []models.Movie) error {
+	userId, err := utils.GetUserIdFromContext(c)
+	if err != nil || len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(movies))
+	for _, m := range movies {
+		ids = append(ids, m.ImdbID)
+	}
+
+	ctx, cancel := context.WithTimeout(c, 100*time.Second)
+	defer cancel()
+
+	var watchlistCollection *mongo.Collection = database.OpenCollection("watchlist", client)
+
+	cursor, err := watchlistCollection.Find(ctx,
+		bson.M{"user_id": userId, "imdb_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"imdb_id": 1}),
+	)
+	if err != nil {
+		return err
+	}
+
+	var entries []models.WatchlistEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return err
+	}
+
+	saved := map[string]bool{}
+	for _, e := range entries {
+		saved[e.ImdbID] = true
+	}
+
+	for i := range movies {
+		inWatchlist := saved[movies[i].ImdbID]
+		movies[i].InWatchlist = &inWatchlist
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// UserEmailIndex is the unique index on users.email. Handlers match it by
+// name to tell a taken email apart from other duplicate key errors.
+const UserEmailIndex = "user_email_unique"
+
+// collectionIndexes lists the indexes the API relies on, keyed by collection.
+var collectionIndexes = map[string][]mongo.IndexModel{
+	"movies": {
+		{
+			Keys:    bson.D{{Key: "imdb_id", Value: 1}},
+			Options: options.Index().SetName("movie_imdb_id_unique").SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "title", Value: "text"},
+				{Key: "admin_review", Value: "text"},
+			},
+			Options: options.Index().
+				SetName("movie_text_search").
+				SetWeights(bson.D{
+					{Key: "title", Value: 10},
+					{Key: "admin_review", Value: 2},
+				}),
+		},
+		{
+			Keys: bson.D{{Key: "directors", Value: 1}},
+			Options: options.Index().
+				SetName("movie_directors").
+				SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+		},
+		{
+			Keys: bson.D{{Key: "cast", Value: 1}},
+			Options: options.Index().
+				SetName("movie_cast").
+				SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+		},
+	},
+	"genres": {
+		{
+			Keys:    bson.D{{Key: "genre_id", Value: 1}},
+			Options: options.Index().SetName("genre_id_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "genre_name", Value: 1}},
+			Options: options.Index().SetName("genre_name_unique").SetUnique(true),
+		},
+	},
+	"jobs": {
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "type", Value: 1}, {Key: "imdb_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	},
+	"rankings": {
+		{
+			Keys:    bson.D{{Key: "ranking_value", Value: 1}},
+			Options: options.Index().SetName("ranking_value_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "ranking_name", Value: 1}},
+			Options: options.Index().SetName("ranking_name_unique").SetUnique(true),
+		},
+	},
+	"rerank_changes": {
+		{
+			Keys: bson.D{{Key: "run_id", Value: 1}, {Key: "imdb_id", Value: 1}},
+		},
+	},
+	"watch_history": {
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "imdb_id", Value: 1}},
+			Options: options.Index().SetName("watch_history_user_movie_unique").SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "watched_at", Value: -1}},
+		},
+	},
+	"watchlist": {
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "imdb_id", Value: 1}},
+			Options: options.Index().SetName("watchlist_user_movie_unique").SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "position", Value: 1}},
+		},
+	},
+	"login_attempts": {
+		{
+			// Counters whose window and lockout have both passed are dropped.
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("login_attempt_expiry_ttl").SetExpireAfterSeconds(0),
+		},
+	},
+	"login_audit": {
+		{
+			Keys: bson.D{{Key: "created_at", Value: -1}},
+		},
+	},
+	"movie_similarities": {
+		{
+			Keys:    bson.D{{Key: "imdb_id", Value: 1}},
+			Options: options.Index().SetName("movie_similarity_imdb_id_unique").SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "computed_at", Value: 1}},
+		},
+	},
+	"ratings": {
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "imdb_id", Value: 1}},
+			Options: options.Index().SetName("rating_user_movie_unique").SetUnique(true),
+		},
+	},
+	"user_reviews": {
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "imdb_id", Value: 1}},
+			Options: options.Index().SetName("user_review_user_movie_unique").SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "imdb_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+	},
+	"sessions": {
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_used_at", Value: -1}},
+		},
+		{
+			// Expired sessions are no longer usable, so MongoDB removes them.
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("session_expiry_ttl").SetExpireAfterSeconds(0),
+		},
+	},
+	"api_keys": {
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetName("api_key_hash_unique").SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	},
+	"revoked_tokens": {
+		{
+			Keys:    bson.D{{Key: "jti", Value: 1}},
+			Options: options.Index().SetName("revoked_token_jti_unique").SetUnique(true),
+		},
+		{
+			// A revoked token is rejected anyway once it expires.
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("revoked_token_expiry_ttl").SetExpireAfterSeconds(0),
+		},
+	},
+	"user_tokens": {
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetName("user_token_hash_unique").SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}},
+		},
+		{
+			// Used and expired tokens are useless, so MongoDB removes them.
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("user_token_expiry_ttl").SetExpireAfterSeconds(0),
+		},
+	},
+	"users": {
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName(UserEmailIndex).SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("user_user_id_unique").SetUnique(true),
+		},
+	},
+}
+
+// IsDuplicateKeyOn reports whether err is a duplicate key error raised by the
+// unique index with the given name. The server only names the index in the
+// error message, e.g. "E11000 duplicate key error collection: db.users index:
+// user_email_unique dup key: { ... }".
+func IsDuplicateKeyOn(err error, index string) bool {
+	if !mongo.IsDuplicateKeyError(err) {
+		return false
+	}
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	return serverErr.HasErrorMessage("index: " + index + " ")
+}
+
+// EnsureIndexes creates the indexes the API relies on. CreateMany is a no-op
+// for indexes that already exist with the same definition, so it is safe to
+// call on every startup. Creating a unique index fails while duplicates are
+// still present, which surfaces bad data instead of silently ignoring it.
+func EnsureIndexes(client *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	for name, indexes := range collectionIndexes {
+		collection := OpenCollection(name, client)
+		if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil {
+			return fmt.Errorf("creating indexes on %s: %w", name, err)
+		}
+	}
+
+	log.Println("MongoDB indexes are in place")
+	return nil
+}
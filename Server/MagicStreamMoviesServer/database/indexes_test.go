@@ -0,0 +1,36 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestIsDuplicateKeyOn(t *testing.T) {
+	duplicate := func(index string) error {
+		return mongo.WriteException{WriteErrors: []mongo.WriteError{{
+			Code:    11000,
+			Message: fmt.Sprintf(`E11000 duplicate key error collection: magic.users index: %s dup key: { x: "1" }`, index),
+		}}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"email index", duplicate(UserEmailIndex), true},
+		{"wrapped email index", fmt.Errorf("inserting user: %w", duplicate(UserEmailIndex)), true},
+		{"user id index", duplicate("user_user_id_unique"), false},
+		{"index sharing a prefix", duplicate(UserEmailIndex + "_v2"), false},
+		{"command error", mongo.CommandError{Code: 11000, Message: "E11000 duplicate key error collection: magic.users index: " + UserEmailIndex + " dup key: { email: \"a\" }"}, true},
+		{"other write error", mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 121, Message: "index: " + UserEmailIndex + " "}}}, false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsDuplicateKeyOn(tt.err, UserEmailIndex); got != tt.want {
+			t.Errorf("%s: IsDuplicateKeyOn = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
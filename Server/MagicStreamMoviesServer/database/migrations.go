@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// RunMigrations brings documents written by older versions of the API up to
+// date. Every step only touches documents that still need it, so it is safe
+// to run on every startup.
+func RunMigrations(client *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	rankingCollection := OpenCollection("rankings", client)
+
+	// Rankings used to be excluded from AI prompts by giving them the value
+	// 999; that is now the explicit selectable_by_ai flag.
+	_, err := rankingCollection.UpdateMany(ctx,
+		bson.M{"selectable_by_ai": bson.M{"$exists": false}, "ranking_value": 999},
+		bson.M{"$set": bson.M{"selectable_by_ai": false}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = rankingCollection.UpdateMany(ctx,
+		bson.M{"selectable_by_ai": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"selectable_by_ai": true}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Movies created before audience ratings existed start out unrated, so
+	// that sorting by rating_average treats them like any other movie.
+	movieCollection := OpenCollection("movies", client)
+	_, err = movieCollection.UpdateMany(ctx,
+		bson.M{"rating_count": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"rating_average": 0, "rating_count": 0, "rating_sum": 0}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Access and refresh tokens used to be stored in plain text on the user.
+	// Refresh tokens now live hashed in the sessions collection.
+	userCollection := OpenCollection("users", client)
+	_, err = userCollection.UpdateMany(ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"token": bson.M{"$exists": true}},
+			bson.M{"refresh_token": bson.M{"$exists": true}},
+		}},
+		bson.M{"$unset": bson.M{"token": "", "refresh_token": ""}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Accounts created before email verification existed keep working; only
+	// new registrations have to confirm their address.
+	_, err = userCollection.UpdateMany(ctx,
+		bson.M{"email_verified": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"email_verified": true}},
+	)
+	return err
+}
@@ -0,0 +1,184 @@
+// Package jobs is a small MongoDB-backed job queue. Jobs live in the jobs
+// collection and are claimed by workers with an atomic FindOneAndUpdate, so
+// several server instances can share the same queue.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	defaultMaxAttempts = 5
+	// lockDuration is how long a claimed job stays reserved. A job whose
+	// worker died is picked up again once the lock expires.
+	lockDuration = 5 * time.Minute
+)
+
+// EnqueueReviewRanking schedules ranking of review for the movie. Pending jobs
+// for the same movie are superseded, since only the latest review matters.
+func EnqueueReviewRanking(ctx context.Context, client *mongo.Client, imdbID string, review string) (models.Job, error) {
+	var jobCollection *mongo.Collection = database.OpenCollection("jobs", client)
+
+	now := time.Now()
+
+	_, err := jobCollection.UpdateMany(ctx,
+		bson.M{"type": models.JobTypeRankReview, "imdb_id": imdbID, "status": models.JobStatusPending},
+		bson.M{"$set": bson.M{"status": models.JobStatusSuperseded, "updated_at": now}},
+	)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job := models.Job{
+		Type:        models.JobTypeRankReview,
+		ImdbID:      imdbID,
+		Review:      review,
+		Status:      models.JobStatusPending,
+		MaxAttempts: defaultMaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	result, err := jobCollection.InsertOne(ctx, job)
+	if err != nil {
+		return models.Job{}, err
+	}
+	job.ID = result.InsertedID.(bson.ObjectID)
+
+	return job, nil
+}
+
+// EnqueueUserReviewLabel schedules sentiment labelling of a user review.
+// Pending jobs for the same review are superseded, since only the latest text
+// matters.
+func EnqueueUserReviewLabel(ctx context.Context, client *mongo.Client, review models.UserReview) (models.Job, error) {
+	var jobCollection *mongo.Collection = database.OpenCollection("jobs", client)
+
+	now := time.Now()
+
+	_, err := jobCollection.UpdateMany(ctx,
+		bson.M{"type": models.JobTypeLabelUserReview, "review_id": review.ID, "status": models.JobStatusPending},
+		bson.M{"$set": bson.M{"status": models.JobStatusSuperseded, "updated_at": now}},
+	)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job := models.Job{
+		Type:        models.JobTypeLabelUserReview,
+		ImdbID:      review.ImdbID,
+		ReviewID:    review.ID,
+		Review:      review.Text,
+		Status:      models.JobStatusPending,
+		MaxAttempts: defaultMaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	result, err := jobCollection.InsertOne(ctx, job)
+	if err != nil {
+		return models.Job{}, err
+	}
+	job.ID = result.InsertedID.(bson.ObjectID)
+
+	return job, nil
+}
+
+// LatestReviewRankingJob returns the most recent ranking job for the movie, or
+// nil if it never had one.
+func LatestReviewRankingJob(ctx context.Context, client *mongo.Client, imdbID string) (*models.Job, error) {
+	var jobCollection *mongo.Collection = database.OpenCollection("jobs", client)
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	var job models.Job
+	err := jobCollection.FindOne(ctx, bson.M{"type": models.JobTypeRankReview, "imdb_id": imdbID}, opts).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// claim reserves the next due job, either a pending one whose run_at has
+// passed or a running one whose lock expired.
+func claim(ctx context.Context, client *mongo.Client) (*models.Job, error) {
+	var jobCollection *mongo.Collection = database.OpenCollection("jobs", client)
+
+	now := time.Now()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.JobStatusPending, "run_at": bson.M{"$lte": now}},
+		bson.M{"status": models.JobStatusRunning, "locked_until": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"status":       models.JobStatusRunning,
+			"locked_until": now.Add(lockDuration),
+			"updated_at":   now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "run_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.Job
+	err := jobCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// finish records the final status of the job. extra, if not nil, holds more
+// fields to set along with it.
+func finish(ctx context.Context, client *mongo.Client, job *models.Job, status string, lastError string, extra bson.M) error {
+	var jobCollection *mongo.Collection = database.OpenCollection("jobs", client)
+
+	set := bson.M{"status": status, "last_error": lastError, "updated_at": time.Now()}
+	for k, v := range extra {
+		set[k] = v
+	}
+
+	_, err := jobCollection.UpdateOne(ctx,
+		bson.M{"_id": job.ID},
+		bson.M{
+			"$set":   set,
+			"$unset": bson.M{"locked_until": ""},
+		},
+	)
+	return err
+}
+
+func retryAt(ctx context.Context, client *mongo.Client, job *models.Job, runAt time.Time, lastError string) error {
+	var jobCollection *mongo.Collection = database.OpenCollection("jobs", client)
+
+	_, err := jobCollection.UpdateOne(ctx,
+		bson.M{"_id": job.ID},
+		bson.M{
+			"$set": bson.M{
+				"status":     models.JobStatusPending,
+				"run_at":     runAt,
+				"last_error": lastError,
+				"updated_at": time.Now(),
+			},
+			"$unset": bson.M{"locked_until": ""},
+		},
+	)
+	return err
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// testClient connects to the MongoDB named by MONGODB_TEST_URI and points
+// OpenCollection at a throwaway database.
+func testClient(t *testing.T) *mongo.Client {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	databaseName := fmt.Sprintf("magicstream_test_%d", time.Now().UnixNano())
+	t.Setenv("DATABASE_NAME", databaseName)
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_ = client.Database(databaseName).Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	return client
+}
+
+func TestClaimTakesDueJobsOnce(t *testing.T) {
+	client := testClient(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	jobCollection := database.OpenCollection("jobs", client)
+	_, err := jobCollection.InsertMany(ctx, []models.Job{
+		{ImdbID: "future", Status: models.JobStatusPending, RunAt: now.Add(time.Hour), MaxAttempts: 5},
+		{ImdbID: "locked", Status: models.JobStatusRunning, RunAt: now.Add(-time.Hour), LockedUntil: now.Add(time.Minute), Attempts: 1, MaxAttempts: 5},
+		{ImdbID: "due", Status: models.JobStatusPending, RunAt: now.Add(-time.Minute), MaxAttempts: 5},
+		{ImdbID: "expired", Status: models.JobStatusRunning, RunAt: now.Add(-2 * time.Minute), LockedUntil: now.Add(-time.Second), Attempts: 1, MaxAttempts: 5},
+		{ImdbID: "done", Status: models.JobStatusDone, RunAt: now.Add(-time.Hour), MaxAttempts: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Oldest run_at first: the job whose worker died, then the due one.
+	for _, want := range []struct {
+		imdbID   string
+		attempts int
+	}{{"expired", 2}, {"due", 1}} {
+		job, err := claim(ctx, client)
+		if err != nil {
+			t.Fatalf("claim: %v", err)
+		}
+		if job == nil || job.ImdbID != want.imdbID {
+			t.Fatalf("claimed %+v, want %s", job, want.imdbID)
+		}
+		if job.Status != models.JobStatusRunning || job.Attempts != want.attempts || !job.LockedUntil.After(now) {
+			t.Errorf("claimed job %s = %+v, want running with attempt %d and a fresh lock", want.imdbID, job, want.attempts)
+		}
+	}
+
+	job, err := claim(ctx, client)
+	if err != nil || job != nil {
+		t.Fatalf("claim with nothing due = %+v, %v; want nil", job, err)
+	}
+}
@@ -0,0 +1,218 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/classifier"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/ranking"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	pollInterval = 2 * time.Second
+	baseBackoff  = 5 * time.Second
+	maxBackoff   = 10 * time.Minute
+	// recordTimeout bounds the writes that record how a job ended.
+	recordTimeout = 30 * time.Second
+)
+
+var errUnknownJobType = errors.New("unknown job type")
+
+// StartWorkers runs n workers that process queued jobs until ctx is cancelled.
+func StartWorkers(ctx context.Context, client *mongo.Client, n int) {
+	for i := 0; i < n; i++ {
+		go work(ctx, client)
+	}
+	log.Printf("Started %d ranking workers", n)
+}
+
+func work(ctx context.Context, client *mongo.Client) {
+	for {
+		job, err := claim(ctx, client)
+		if err != nil {
+			log.Println("jobs: claim failed:", err)
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		process(ctx, client, job)
+	}
+}
+
+// process runs the job within its lock. The outcome is recorded on a context
+// of its own, so that a job whose classifier call used up the lock is still
+// marked failed or rescheduled instead of staying running until the lock
+// expires.
+func process(ctx context.Context, client *mongo.Client, job *models.Job) {
+	jobCtx, cancel := context.WithTimeout(ctx, lockDuration)
+	defer cancel()
+
+	var err error
+	switch job.Type {
+	case models.JobTypeRankReview:
+		err = rankReview(jobCtx, client, job)
+	case models.JobTypeLabelUserReview:
+		err = labelUserReview(jobCtx, client, job)
+	default:
+		err = errUnknownJobType
+	}
+
+	recordCtx, cancelRecord := context.WithTimeout(ctx, recordTimeout)
+	defer cancelRecord()
+
+	if errors.Is(err, errUnknownJobType) {
+		if err := finish(recordCtx, client, job, models.JobStatusFailed, "unknown job type "+job.Type, nil); err != nil {
+			log.Println("jobs: could not mark job as failed:", err)
+		}
+		return
+	}
+	if err != nil {
+		handleFailure(recordCtx, client, job, err)
+		return
+	}
+	if err := finish(recordCtx, client, job, models.JobStatusDone, "", nil); err != nil {
+		log.Println("jobs: could not mark job as done:", err)
+	}
+}
+
+// rankReview classifies the job's review and stores the ranking on the movie,
+// unless the review was changed again in the meantime.
+func rankReview(ctx context.Context, client *mongo.Client, job *models.Job) error {
+	result, err := ranking.RankReview(ctx, client, job.Review)
+	if err != nil {
+		return err
+	}
+
+	// Claiming the ranking keeps a rename or delete that raced with the
+	// classifier from leaving a stale copy; the job is retried instead.
+	return database.WithTransaction(ctx, client, func(ctx context.Context) error {
+		if err := ranking.Resolve(ctx, client, result); err != nil {
+			return err
+		}
+
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+		_, err := movieCollection.UpdateOne(ctx,
+			bson.M{"imdb_id": job.ImdbID, "admin_review": job.Review},
+			bson.M{"$set": bson.M{
+				"ranking": bson.M{
+					"ranking_value": result.RankingValue,
+					"ranking_name":  result.RankingName,
+				},
+				"ranking_status": models.RankingStatusRanked,
+			}},
+		)
+		return err
+	})
+}
+
+// labelUserReview classifies a user review and stores the result as its
+// sentiment, unless the author edited the text in the meantime.
+func labelUserReview(ctx context.Context, client *mongo.Client, job *models.Job) error {
+	result, err := ranking.RankReview(ctx, client, job.Review)
+	if err != nil {
+		return err
+	}
+
+	return database.WithTransaction(ctx, client, func(ctx context.Context) error {
+		if err := ranking.Resolve(ctx, client, result); err != nil {
+			return err
+		}
+
+		var reviewCollection *mongo.Collection = database.OpenCollection("user_reviews", client)
+
+		_, err := reviewCollection.UpdateOne(ctx,
+			bson.M{"_id": job.ReviewID, "text": job.Review},
+			bson.M{"$set": bson.M{
+				"sentiment": bson.M{
+					"ranking_value": result.RankingValue,
+					"ranking_name":  result.RankingName,
+				},
+				"sentiment_status": models.RankingStatusRanked,
+			}},
+		)
+		return err
+	})
+}
+
+func handleFailure(ctx context.Context, client *mongo.Client, job *models.Job, jobErr error) {
+	log.Printf("jobs: %s for %s failed (attempt %d/%d): %v", job.Type, job.ImdbID, job.Attempts, job.MaxAttempts, jobErr)
+
+	retry, extra := failureOutcome(job, jobErr)
+	if retry {
+		if err := retryAt(ctx, client, job, time.Now().Add(backoff(job.Attempts)), jobErr.Error()); err != nil {
+			log.Println("jobs: could not reschedule job:", err)
+		}
+		return
+	}
+
+	if err := finish(ctx, client, job, models.JobStatusFailed, jobErr.Error(), extra); err != nil {
+		log.Println("jobs: could not mark job as failed:", err)
+	}
+
+	if err := markFailed(ctx, client, job); err != nil {
+		log.Println("jobs: could not record failed status:", err)
+	}
+}
+
+// failureOutcome decides whether a job that failed with jobErr is retried. A
+// job that fails for good gets extra fields to record with its status, or nil.
+func failureOutcome(job *models.Job, jobErr error) (bool, bson.M) {
+	// The classifier already retried with a corrective prompt, so an
+	// unrecognized answer will not get better by trying again later. Keep
+	// what the model was allowed to answer, so that GetRankingStatus can
+	// report why the movie could not be ranked.
+	var rankingErr *classifier.UnrecognizedRankingError
+	if errors.As(jobErr, &rankingErr) {
+		return false, bson.M{
+			"error_code":       models.JobErrorUnrecognizedRanking,
+			"allowed_rankings": rankingErr.Allowed,
+		}
+	}
+	return job.Attempts < job.MaxAttempts, nil
+}
+
+// markFailed flags the document the job was working on, as long as it still
+// holds the text the job was given.
+func markFailed(ctx context.Context, client *mongo.Client, job *models.Job) error {
+	switch job.Type {
+	case models.JobTypeLabelUserReview:
+		var reviewCollection *mongo.Collection = database.OpenCollection("user_reviews", client)
+		_, err := reviewCollection.UpdateOne(ctx,
+			bson.M{"_id": job.ReviewID, "text": job.Review},
+			bson.M{"$set": bson.M{"sentiment_status": models.RankingStatusFailed}},
+		)
+		return err
+	default:
+		var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+		_, err := movieCollection.UpdateOne(ctx,
+			bson.M{"imdb_id": job.ImdbID, "admin_review": job.Review},
+			bson.M{"$set": bson.M{"ranking_status": models.RankingStatusFailed}},
+		)
+		return err
+	}
+}
+
+// backoff doubles the delay with every attempt, capped at maxBackoff, and adds
+// up to 20% jitter so failed jobs do not retry in lockstep.
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff << (attempts - 1)
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
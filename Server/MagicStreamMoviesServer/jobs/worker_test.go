@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/classifier"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		base     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{7, 320 * time.Second},
+		{8, maxBackoff},
+		{20, maxBackoff},
+		// A shift this large overflows; it must still be capped.
+		{70, maxBackoff},
+	}
+	for _, tt := range tests {
+		for range 50 {
+			got := backoff(tt.attempts)
+			if got < tt.base || got > tt.base+tt.base/5 {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempts, got, tt.base, tt.base+tt.base/5)
+			}
+		}
+	}
+
+	for attempts := 1; attempts <= 100; attempts++ {
+		if got := backoff(attempts); got < baseBackoff || got > maxBackoff+maxBackoff/5 {
+			t.Errorf("backoff(%d) = %v, want between %v and the jittered cap", attempts, got, baseBackoff)
+		}
+	}
+}
+
+func TestFailureOutcome(t *testing.T) {
+	unrecognized := &classifier.UnrecognizedRankingError{Answer: "Meh", Allowed: []string{"Excellent", "Good"}}
+
+	tests := []struct {
+		name      string
+		attempts  int
+		err       error
+		retry     bool
+		errorCode string
+	}{
+		{"first failure", 1, errors.New("connection refused"), true, ""},
+		{"last attempt left", 4, errors.New("connection refused"), true, ""},
+		{"attempts used up", 5, errors.New("connection refused"), false, ""},
+		{"unrecognized ranking", 1, unrecognized, false, models.JobErrorUnrecognizedRanking},
+		{"wrapped unrecognized ranking", 1, fmt.Errorf("ranking review: %w", unrecognized), false, models.JobErrorUnrecognizedRanking},
+	}
+	for _, tt := range tests {
+		job := &models.Job{Attempts: tt.attempts, MaxAttempts: 5}
+		retry, extra := failureOutcome(job, tt.err)
+		if retry != tt.retry {
+			t.Errorf("%s: retry = %v, want %v", tt.name, retry, tt.retry)
+		}
+
+		code, _ := extra["error_code"].(string)
+		if code != tt.errorCode {
+			t.Errorf("%s: error_code = %q, want %q", tt.name, code, tt.errorCode)
+		}
+		if tt.errorCode != "" {
+			if allowed, _ := extra["allowed_rankings"].([]string); len(allowed) != 2 {
+				t.Errorf("%s: allowed_rankings = %v, want the classifier's list", tt.name, extra["allowed_rankings"])
+			}
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Package loginguard slows down and then temporarily blocks password guessing
+// on the login endpoint. Failed attempts are counted in a sliding window per
+// account and per client IP: after a few free failures every further attempt
+// has to wait progressively longer, and reaching the limit locks the account
+// or IP out for a while. The same store also throttles the unauthenticated
+// endpoints that send account emails.
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Limit sets how many failures within the window are tolerated.
+type Limit struct {
+	// FreeFailures are allowed without any delay.
+	FreeFailures int
+	// MaxFailures trigger a lockout.
+	MaxFailures int
+}
+
+type Policy struct {
+	Window   time.Duration
+	Lockout  time.Duration
+	Account  Limit
+	IP       Limit
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// DefaultPolicy allows a handful of typos per account. The IP limit is
+// higher because many users can share an address behind NAT.
+var DefaultPolicy = Policy{
+	Window:   15 * time.Minute,
+	Lockout:  15 * time.Minute,
+	Account:  Limit{FreeFailures: 2, MaxFailures: 5},
+	IP:       Limit{FreeFailures: 10, MaxFailures: 50},
+	MinDelay: time.Second,
+	MaxDelay: 30 * time.Second,
+}
+
+// Decision tells the login handler whether to check the password at all.
+type Decision struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	// Reason is account_locked, ip_locked or too_many_attempts when the
+	// attempt is not allowed.
+	Reason string
+}
+
+// maxReserveTries bounds how often Begin decides again after losing a race
+// against concurrent attempts for the same key.
+const maxReserveTries = 5
+
+type Guard struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+func New(store Store, policy Policy) *Guard {
+	return &Guard{store: store, policy: policy, now: time.Now}
+}
+
+// Attempt is a login attempt reserved by Begin. It has to be settled with
+// Fail or Succeed once the password has been checked.
+type Attempt struct {
+	email string
+	ip    string
+	at    time.Time
+}
+
+var (
+	defaultMu            sync.RWMutex
+	defaultStore         = NewMemoryStore()
+	defaultGuard         = New(defaultStore, DefaultPolicy)
+	defaultEmailThrottle = NewEmailThrottle(defaultStore, DefaultEmailLimits)
+)
+
+// Load configures the guard returned by Default and the throttle returned by
+// Emails from LOGIN_GUARD_STORE:
+//
+//	mongo  - attempts are shared by all instances (default)
+//	memory - attempts are kept per process
+func Load(client *mongo.Client) error {
+	var store Store
+	switch kind := strings.ToLower(os.Getenv("LOGIN_GUARD_STORE")); kind {
+	case "", "mongo", "mongodb":
+		store = NewMongoStore(client)
+	case "memory":
+		store = NewMemoryStore()
+	default:
+		return fmt.Errorf("unknown LOGIN_GUARD_STORE %q", kind)
+	}
+
+	defaultMu.Lock()
+	defaultGuard = New(store, DefaultPolicy)
+	defaultEmailThrottle = NewEmailThrottle(store, DefaultEmailLimits)
+	defaultMu.Unlock()
+	return nil
+}
+
+// Default returns the guard configured by Load.
+func Default() *Guard {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultGuard
+}
+
+// Emails returns the account email throttle configured by Load.
+func Emails() *EmailThrottle {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultEmailThrottle
+}
+
+func accountKey(email string) string {
+	return "account:" + normalizeEmail(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Begin decides whether a login attempt for email from ip may go ahead and,
+// if so, counts it against both the account and the IP before the password
+// is checked. Counting first is what makes the limits hold for concurrent
+// requests: each one sees the attempts reserved before it, so firing guesses
+// in parallel runs into the delay and the lockout like sequential ones do.
+func (g *Guard) Begin(ctx context.Context, email string, ip string) (*Attempt, Decision, error) {
+	// MongoDB keeps milliseconds, and RemoveAttempt has to find the attempt
+	// again by its time.
+	now := g.now().Truncate(time.Millisecond)
+	attempt := &Attempt{email: normalizeEmail(email), ip: ip, at: now}
+
+	decision, err := g.reserve(ctx, accountKey(email), g.policy.Account, "account_locked", now)
+	if err != nil || !decision.Allowed {
+		return nil, decision, err
+	}
+
+	decision, err = g.reserve(ctx, ipKey(ip), g.policy.IP, "ip_locked", now)
+	if err != nil || !decision.Allowed {
+		// The attempt never reached the password check, so it does not
+		// count against the account.
+		if err := g.store.RemoveAttempt(ctx, accountKey(email), now); err != nil {
+			log.Printf("Failed to release login attempt: %v", err)
+		}
+		return nil, decision, err
+	}
+
+	return attempt, decision, nil
+}
+
+// reserve records an attempt for key unless the key is locked out, has used
+// up its attempts or has to wait. It decides on the state it read and only
+// records if nothing changed in between, deciding again otherwise.
+func (g *Guard) reserve(ctx context.Context, key string, limit Limit, lockedReason string, now time.Time) (Decision, error) {
+	for range maxReserveTries {
+		state, err := g.store.Get(ctx, key, now.Add(-g.policy.Window))
+		if err != nil {
+			return Decision{}, err
+		}
+
+		if state.LockedUntil.After(now) {
+			return Decision{RetryAfter: state.LockedUntil.Sub(now), Reason: lockedReason}, nil
+		}
+		// Attempts still being checked count too; the lockout follows as
+		// soon as the last of them fails.
+		if len(state.Failures) >= limit.MaxFailures {
+			return Decision{RetryAfter: g.policy.Lockout, Reason: lockedReason}, nil
+		}
+		if wait := g.wait(state.Failures, limit, now); wait > 0 {
+			return Decision{RetryAfter: wait, Reason: "too_many_attempts"}, nil
+		}
+
+		err = g.store.RecordAttempt(ctx, key, state.Version, now, g.policy.Window)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return Decision{}, err
+		}
+		return Decision{Allowed: true}, nil
+	}
+
+	// Heavy contention on one key is itself a sign of an attack.
+	return Decision{RetryAfter: g.policy.MinDelay, Reason: "too_many_attempts"}, nil
+}
+
+// wait returns how long the next attempt still has to wait. Every failure
+// past the free ones doubles the delay after the latest failure.
+func (g *Guard) wait(failures []time.Time, limit Limit, now time.Time) time.Duration {
+	extra := len(failures) - limit.FreeFailures
+	if extra <= 0 {
+		return 0
+	}
+
+	delay := g.policy.MaxDelay
+	if extra <= 16 {
+		delay = min(g.policy.MinDelay<<(extra-1), g.policy.MaxDelay)
+	}
+
+	next := failures[len(failures)-1].Add(delay)
+	if !next.After(now) {
+		return 0
+	}
+	return next.Sub(now)
+}
+
+// Fail confirms a reserved attempt as failed and locks out the account or IP
+// if it reached its limit.
+func (g *Guard) Fail(ctx context.Context, attempt *Attempt) error {
+	if err := g.fail(ctx, accountKey(attempt.email), g.policy.Account, models.LoginScopeAccount, attempt.email, attempt.ip); err != nil {
+		return err
+	}
+	return g.fail(ctx, ipKey(attempt.ip), g.policy.IP, models.LoginScopeIP, attempt.ip, attempt.ip)
+}
+
+func (g *Guard) fail(ctx context.Context, key string, limit Limit, scope string, subject string, ip string) error {
+	now := g.now()
+	state, err := g.store.Get(ctx, key, now.Add(-g.policy.Window))
+	if err != nil {
+		return err
+	}
+	if len(state.Failures) < limit.MaxFailures {
+		return nil
+	}
+
+	until := now.Add(g.policy.Lockout)
+	locked, err := g.store.Lock(ctx, key, now, until)
+	if err != nil || !locked {
+		return err
+	}
+
+	log.Printf("Login lockout of %s %s until %s after %d failed attempts", scope, subject, until.Format(time.RFC3339), len(state.Failures))
+	return g.store.AddAuditEvent(ctx, models.LoginAuditEvent{
+		Type:        models.LoginAuditLockout,
+		Scope:       scope,
+		Subject:     subject,
+		IP:          ip,
+		Failures:    len(state.Failures),
+		LockedUntil: &until,
+		CreatedAt:   now,
+	})
+}
+
+// Succeed settles a reserved attempt whose password was right. The account's
+// failures are forgotten; the IP only gets this attempt back, so that logging
+// in to one account between guesses at others does not reset its counter.
+func (g *Guard) Succeed(ctx context.Context, attempt *Attempt) error {
+	if err := g.store.Reset(ctx, accountKey(attempt.email)); err != nil {
+		return err
+	}
+	return g.store.RemoveAttempt(ctx, ipKey(attempt.ip), attempt.at)
+}
+
+// Unlock lifts a lockout of the account and forgets its failures on behalf of
+// the admin actorId. It reports whether the account was locked.
+func (g *Guard) Unlock(ctx context.Context, email string, actorId string) (bool, error) {
+	now := g.now()
+	state, err := g.store.Get(ctx, accountKey(email), now.Add(-g.policy.Window))
+	if err != nil {
+		return false, err
+	}
+	locked := state.LockedUntil.After(now)
+
+	if err := g.store.Reset(ctx, accountKey(email)); err != nil {
+		return false, err
+	}
+
+	return locked, g.store.AddAuditEvent(ctx, models.LoginAuditEvent{
+		Type:      models.LoginAuditUnlock,
+		Scope:     models.LoginScopeAccount,
+		Subject:   normalizeEmail(email),
+		ActorID:   actorId,
+		CreatedAt: now,
+	})
+}
+
+// Forget removes the failed attempts, lockout and audit events of the account
+// with the given email, for an account that is being deleted.
+func (g *Guard) Forget(ctx context.Context, email string) error {
+	if err := g.store.Reset(ctx, accountKey(email)); err != nil {
+		return err
+	}
+	return g.store.DeleteAuditEvents(ctx, models.LoginScopeAccount, normalizeEmail(email))
+}
+
+// AuditEvents returns the latest lockout and unlock events, newest first.
+func (g *Guard) AuditEvents(ctx context.Context, limit int) ([]models.LoginAuditEvent, error) {
+	return g.store.AuditEvents(ctx, limit)
+}
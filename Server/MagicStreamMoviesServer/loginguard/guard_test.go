@@ -0,0 +1,284 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestGuard() (*Guard, *fakeClock, *MemoryStore) {
+	clock := &fakeClock{t: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	g := New(store, DefaultPolicy)
+	g.now = clock.now
+	return g, clock, store
+}
+
+// failOnce runs one failed login, waiting out any delay first. It fails the
+// test if the attempt is turned away for another reason.
+func failOnce(t *testing.T, g *Guard, clock *fakeClock, email, ip string) {
+	t.Helper()
+	attempt, decision, err := g.Begin(context.Background(), email, ip)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if !decision.Allowed && decision.Reason == "too_many_attempts" {
+		clock.advance(decision.RetryAfter)
+		attempt, decision, err = g.Begin(context.Background(), email, ip)
+		if err != nil {
+			t.Fatalf("Begin: %v", err)
+		}
+	}
+	if !decision.Allowed {
+		t.Fatalf("attempt not allowed: %+v", decision)
+	}
+	if err := g.Fail(context.Background(), attempt); err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+}
+
+func TestWaitDoublesPerFailurePastTheFreeOnes(t *testing.T) {
+	g, clock, _ := newTestGuard()
+	now := clock.now()
+	limit := Limit{FreeFailures: 2, MaxFailures: 100}
+
+	failures := func(n int) []time.Time {
+		times := make([]time.Time, n)
+		for i := range times {
+			times[i] = now
+		}
+		return times
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{7, 16 * time.Second},
+		{8, 30 * time.Second},
+		{60, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := g.wait(failures(tt.failures), limit, now); got != tt.want {
+			t.Errorf("wait after %d failures = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	// The delay runs from the latest failure.
+	if got := g.wait(failures(4), limit, now.Add(1500*time.Millisecond)); got != 500*time.Millisecond {
+		t.Errorf("wait 1.5s after the latest failure = %v, want 500ms", got)
+	}
+	if got := g.wait(failures(4), limit, now.Add(3*time.Second)); got != 0 {
+		t.Errorf("wait after the delay passed = %v, want 0", got)
+	}
+}
+
+func TestSlidingWindowForgetsOldFailures(t *testing.T) {
+	g, clock, _ := newTestGuard()
+
+	for range 4 {
+		failOnce(t, g, clock, "jane@example.com", "203.0.113.7")
+	}
+
+	_, decision, _ := g.Begin(context.Background(), "jane@example.com", "203.0.113.7")
+	if decision.Allowed {
+		t.Fatal("attempt right after four failures was allowed")
+	}
+
+	// Once the failures have left the window the account starts afresh.
+	clock.advance(DefaultPolicy.Window + time.Second)
+	_, decision, _ = g.Begin(context.Background(), "jane@example.com", "203.0.113.7")
+	if !decision.Allowed {
+		t.Fatalf("attempt after the window was not allowed: %+v", decision)
+	}
+}
+
+func TestLockoutAfterMaxFailuresAndExpiry(t *testing.T) {
+	g, clock, store := newTestGuard()
+	ctx := context.Background()
+
+	for range DefaultPolicy.Account.MaxFailures {
+		failOnce(t, g, clock, "jane@example.com", "203.0.113.7")
+	}
+
+	_, decision, err := g.Begin(ctx, "Jane@Example.com ", "198.51.100.1")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if decision.Allowed || decision.Reason != "account_locked" {
+		t.Fatalf("decision after lockout = %+v, want account_locked", decision)
+	}
+	if decision.RetryAfter <= 0 || decision.RetryAfter > DefaultPolicy.Lockout {
+		t.Errorf("RetryAfter = %v, want within the lockout", decision.RetryAfter)
+	}
+
+	events, _ := store.AuditEvents(ctx, 10)
+	if len(events) != 1 || events[0].Type != models.LoginAuditLockout || events[0].Subject != "jane@example.com" {
+		t.Fatalf("audit events = %+v, want one account lockout", events)
+	}
+
+	clock.advance(DefaultPolicy.Lockout + time.Second)
+	_, decision, _ = g.Begin(ctx, "jane@example.com", "198.51.100.1")
+	if !decision.Allowed {
+		t.Fatalf("attempt after the lockout expired was not allowed: %+v", decision)
+	}
+}
+
+func TestConcurrentAttemptsCannotBypassTheLimits(t *testing.T) {
+	g, _, _ := newTestGuard()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempt, decision, err := g.Begin(context.Background(), "jane@example.com", "203.0.113.7")
+			if err != nil {
+				t.Errorf("Begin: %v", err)
+				return
+			}
+			if !decision.Allowed {
+				return
+			}
+			mu.Lock()
+			allowed++
+			mu.Unlock()
+			if err := g.Fail(context.Background(), attempt); err != nil {
+				t.Errorf("Fail: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Only the free attempts and the first delayed one get through at the
+	// same instant; everything else has to wait for the delay.
+	if want := DefaultPolicy.Account.FreeFailures + 1; allowed != want {
+		t.Errorf("%d concurrent attempts allowed, want %d", allowed, want)
+	}
+}
+
+func TestUnlockLiftsTheLockout(t *testing.T) {
+	g, clock, store := newTestGuard()
+	ctx := context.Background()
+
+	for range DefaultPolicy.Account.MaxFailures {
+		failOnce(t, g, clock, "jane@example.com", "203.0.113.7")
+	}
+
+	wasLocked, err := g.Unlock(ctx, "jane@example.com", "admin-1")
+	if err != nil || !wasLocked {
+		t.Fatalf("Unlock = %v, %v; want true, nil", wasLocked, err)
+	}
+
+	_, decision, _ := g.Begin(ctx, "jane@example.com", "203.0.113.7")
+	if !decision.Allowed {
+		t.Fatalf("attempt after unlock was not allowed: %+v", decision)
+	}
+
+	events, _ := store.AuditEvents(ctx, 10)
+	if len(events) != 2 || events[0].Type != models.LoginAuditUnlock || events[0].ActorID != "admin-1" {
+		t.Fatalf("latest audit event = %+v, want an unlock by admin-1", events)
+	}
+
+	wasLocked, _ = g.Unlock(ctx, "jane@example.com", "admin-1")
+	if wasLocked {
+		t.Error("Unlock of an account that is not locked reported it as locked")
+	}
+}
+
+func TestSucceedResetsTheAccountButNotTheIP(t *testing.T) {
+	g, clock, store := newTestGuard()
+	ctx := context.Background()
+
+	failOnce(t, g, clock, "jane@example.com", "203.0.113.7")
+	failOnce(t, g, clock, "jane@example.com", "203.0.113.7")
+
+	attempt, decision, err := g.Begin(ctx, "jane@example.com", "203.0.113.7")
+	if err != nil || !decision.Allowed {
+		t.Fatalf("Begin = %+v, %v", decision, err)
+	}
+	if err := g.Succeed(ctx, attempt); err != nil {
+		t.Fatalf("Succeed: %v", err)
+	}
+
+	account, _ := store.Get(ctx, accountKey("jane@example.com"), time.Time{})
+	if len(account.Failures) != 0 {
+		t.Errorf("account has %d failures after a successful login, want 0", len(account.Failures))
+	}
+	ip, _ := store.Get(ctx, ipKey("203.0.113.7"), time.Time{})
+	if len(ip.Failures) != 2 {
+		t.Errorf("IP has %d failures, want the 2 failed ones", len(ip.Failures))
+	}
+}
+
+func TestIPLockoutDoesNotCountAgainstTheAccount(t *testing.T) {
+	g, clock, store := newTestGuard()
+	ctx := context.Background()
+
+	until := clock.now().Add(time.Hour)
+	if _, err := store.Lock(ctx, ipKey("203.0.113.7"), clock.now(), until); err != nil {
+		t.Fatal(err)
+	}
+
+	_, decision, _ := g.Begin(ctx, "jane@example.com", "203.0.113.7")
+	if decision.Allowed || decision.Reason != "ip_locked" {
+		t.Fatalf("decision = %+v, want ip_locked", decision)
+	}
+
+	account, _ := store.Get(ctx, accountKey("jane@example.com"), time.Time{})
+	if len(account.Failures) != 0 {
+		t.Errorf("account has %d attempts after an IP lockout, want 0", len(account.Failures))
+	}
+}
+
+func TestForgetRemovesTheAccountsTrail(t *testing.T) {
+	g, clock, store := newTestGuard()
+	ctx := context.Background()
+
+	for range DefaultPolicy.Account.MaxFailures {
+		failOnce(t, g, clock, "jane@example.com", "203.0.113.7")
+	}
+	for range DefaultPolicy.Account.MaxFailures {
+		failOnce(t, g, clock, "john@example.com", "203.0.113.8")
+	}
+
+	if err := g.Forget(ctx, "Jane@Example.com"); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+
+	account, _ := store.Get(ctx, accountKey("jane@example.com"), time.Time{})
+	if account.Version != 0 {
+		t.Errorf("account state after Forget = %+v, want none", account)
+	}
+	events, _ := store.AuditEvents(ctx, 10)
+	if len(events) != 1 || events[0].Subject != "john@example.com" {
+		t.Errorf("audit events after Forget = %+v, want only john's lockout", events)
+	}
+}
@@ -0,0 +1,174 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+// maxMemoryAuditEvents bounds the audit trail kept by MemoryStore.
+const maxMemoryAuditEvents = 1000
+
+// sweepEvery sets how many writes MemoryStore makes between removals of
+// entries that no longer matter.
+const sweepEvery = 1000
+
+type memoryEntry struct {
+	failures    []time.Time
+	lockedUntil time.Time
+	expiresAt   time.Time
+	version     int64
+}
+
+// MemoryStore keeps attempts in process memory. It suits a single instance
+// and local development; counters are lost on restart and not shared between
+// instances.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	events  []models.LoginAuditEvent
+	writes  int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*memoryEntry{}}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string, from time.Time) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return State{}, nil
+	}
+	return State{
+		Failures:    append([]time.Time(nil), since(entry.failures, from)...),
+		LockedUntil: entry.lockedUntil,
+		Version:     entry.version,
+	}, nil
+}
+
+func (s *MemoryStore) RecordAttempt(ctx context.Context, key string, version int64, at time.Time, window time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+	}
+	if entry.version != version {
+		return ErrConflict
+	}
+	s.sweep(at)
+	s.entries[key] = entry
+
+	entry.failures = append(since(entry.failures, at.Add(-window)), at)
+	if len(entry.failures) > maxTrackedFailures {
+		entry.failures = entry.failures[len(entry.failures)-maxTrackedFailures:]
+	}
+	if expires := at.Add(window); expires.After(entry.expiresAt) {
+		entry.expiresAt = expires
+	}
+	entry.version++
+	return nil
+}
+
+func (s *MemoryStore) RemoveAttempt(ctx context.Context, key string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	for i, f := range entry.failures {
+		if f.Equal(at) {
+			entry.failures = append(entry.failures[:i:i], entry.failures[i+1:]...)
+			entry.version++
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, now time.Time, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+	if entry.lockedUntil.After(now) {
+		return false, nil
+	}
+	entry.failures = nil
+	entry.lockedUntil = until
+	if until.After(entry.expiresAt) {
+		entry.expiresAt = until
+	}
+	entry.version++
+	return true, nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) AddAuditEvent(ctx context.Context, event models.LoginAuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	if len(s.events) > maxMemoryAuditEvents {
+		s.events = s.events[len(s.events)-maxMemoryAuditEvents:]
+	}
+	return nil
+}
+
+func (s *MemoryStore) AuditEvents(ctx context.Context, limit int) ([]models.LoginAuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := []models.LoginAuditEvent{}
+	for i := len(s.events) - 1; i >= 0 && len(events) < limit; i-- {
+		events = append(events, s.events[i])
+	}
+	return events, nil
+}
+
+func (s *MemoryStore) DeleteAuditEvents(ctx context.Context, scope string, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.events[:0]
+	for _, e := range s.events {
+		if e.Scope != scope || e.Subject != subject {
+			kept = append(kept, e)
+		}
+	}
+	s.events = kept
+	return nil
+}
+
+// sweep drops entries whose attempts and lockout have both run out. It only
+// does the work every sweepEvery writes. The caller must hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	s.writes++
+	if s.writes%sweepEvery != 0 {
+		return
+	}
+	for key, entry := range s.entries {
+		if entry.expiresAt.Before(now) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// loginAttempts is the document kept per key in the login_attempts
+// collection. A TTL index on expires_at removes it once neither its attempts
+// nor its lockout matter any more.
+type loginAttempts struct {
+	Key         string      `bson:"_id"`
+	Failures    []time.Time `bson:"failures"`
+	LockedUntil time.Time   `bson:"locked_until,omitempty"`
+	ExpiresAt   time.Time   `bson:"expires_at"`
+	Version     int64       `bson:"version"`
+}
+
+// MongoStore shares attempts between all API instances through MongoDB.
+type MongoStore struct {
+	attempts *mongo.Collection
+	audit    *mongo.Collection
+}
+
+func NewMongoStore(client *mongo.Client) *MongoStore {
+	return &MongoStore{
+		attempts: database.OpenCollection("login_attempts", client),
+		audit:    database.OpenCollection("login_audit", client),
+	}
+}
+
+func (s *MongoStore) Get(ctx context.Context, key string, from time.Time) (State, error) {
+	var doc loginAttempts
+	err := s.attempts.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+	return State{Failures: since(doc.Failures, from), LockedUntil: doc.LockedUntil, Version: doc.Version}, nil
+}
+
+// RecordAttempt is a compare-and-swap on the version: of several concurrent
+// attempts that read the same state only one is recorded, the others get
+// ErrConflict and have to decide again with the new attempt counted.
+func (s *MongoStore) RecordAttempt(ctx context.Context, key string, version int64, at time.Time, window time.Duration) error {
+	if version == 0 {
+		_, err := s.attempts.InsertOne(ctx, loginAttempts{
+			Key:       key,
+			Failures:  []time.Time{at},
+			ExpiresAt: at.Add(window),
+			Version:   1,
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrConflict
+		}
+		return err
+	}
+
+	result, err := s.attempts.UpdateOne(ctx,
+		bson.M{"_id": key, "version": version},
+		bson.M{
+			"$push": bson.M{"failures": bson.M{
+				"$each":  bson.A{at},
+				"$sort":  1,
+				"$slice": -maxTrackedFailures,
+			}},
+			"$inc": bson.M{"version": 1},
+			"$max": bson.M{"expires_at": at.Add(window)},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *MongoStore) RemoveAttempt(ctx context.Context, key string, at time.Time) error {
+	_, err := s.attempts.UpdateOne(ctx,
+		bson.M{"_id": key, "failures": at},
+		bson.M{"$pull": bson.M{"failures": at}, "$inc": bson.M{"version": 1}},
+	)
+	return err
+}
+
+func (s *MongoStore) Lock(ctx context.Context, key string, now time.Time, until time.Time) (bool, error) {
+	result, err := s.attempts.UpdateOne(ctx,
+		bson.M{"_id": key, "$or": bson.A{
+			bson.M{"locked_until": bson.M{"$exists": false}},
+			bson.M{"locked_until": bson.M{"$lte": now}},
+		}},
+		bson.M{
+			"$set": bson.M{"failures": bson.A{}, "locked_until": until},
+			"$inc": bson.M{"version": 1},
+			"$max": bson.M{"expires_at": until},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (s *MongoStore) Reset(ctx context.Context, key string) error {
+	_, err := s.attempts.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+func (s *MongoStore) AddAuditEvent(ctx context.Context, event models.LoginAuditEvent) error {
+	_, err := s.audit.InsertOne(ctx, event)
+	return err
+}
+
+func (s *MongoStore) AuditEvents(ctx context.Context, limit int) ([]models.LoginAuditEvent, error) {
+	cursor, err := s.audit.Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+
+	events := []models.LoginAuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *MongoStore) DeleteAuditEvents(ctx context.Context, scope string, subject string) error {
+	_, err := s.audit.DeleteMany(ctx, bson.M{"scope": scope, "subject": subject})
+	return err
+}
@@ -0,0 +1,63 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+// maxTrackedFailures bounds the attempt timestamps kept per key. It only has
+// to exceed the largest MaxFailures of the policy.
+const maxTrackedFailures = 100
+
+// ErrConflict is returned by RecordAttempt when the key changed since it was
+// read, so that the caller decides again on the current state.
+var ErrConflict = errors.New("login attempts changed concurrently")
+
+// State is what a store knows about one account or client IP.
+type State struct {
+	// Failures holds the attempts inside the window, oldest first. Attempts
+	// whose password check is still running are included.
+	Failures    []time.Time
+	LockedUntil time.Time
+	// Version changes with every write to the key. It is 0 for a key the
+	// store knows nothing about.
+	Version int64
+}
+
+// Store keeps login attempts, lockouts and the audit trail. Keys are opaque
+// strings such as "account:jane@example.com" or "ip:203.0.113.7"; the
+// EmailThrottle counts its requests under keys of its own.
+type Store interface {
+	// Get returns the state of key, with attempts before since left out.
+	Get(ctx context.Context, key string, since time.Time) (State, error)
+	// RecordAttempt adds an attempt at the given time, but only if key is
+	// still at version. It returns ErrConflict otherwise.
+	RecordAttempt(ctx context.Context, key string, version int64, at time.Time, window time.Duration) error
+	// RemoveAttempt takes back an attempt that turned out not to be a failure.
+	RemoveAttempt(ctx context.Context, key string, at time.Time) error
+	// Lock rejects attempts for key until the given time and clears its
+	// attempts, so that it starts afresh once the lockout ends. It reports
+	// false without changing anything if key is already locked at now.
+	Lock(ctx context.Context, key string, now time.Time, until time.Time) (bool, error)
+	// Reset forgets everything about key.
+	Reset(ctx context.Context, key string) error
+
+	AddAuditEvent(ctx context.Context, event models.LoginAuditEvent) error
+	// AuditEvents returns the latest events, newest first.
+	AuditEvents(ctx context.Context, limit int) ([]models.LoginAuditEvent, error)
+	// DeleteAuditEvents removes the events about subject in scope.
+	DeleteAuditEvents(ctx context.Context, scope string, subject string) error
+}
+
+// since returns the attempts at or after t.
+func since(failures []time.Time, t time.Time) []time.Time {
+	for i, f := range failures {
+		if !f.Before(t) {
+			return failures[i:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// EmailLimits caps the account emails, such as verification and password
+// reset links, that unauthenticated clients can ask for within the window.
+type EmailLimits struct {
+	Window     time.Duration
+	PerAddress int
+	PerIP      int
+}
+
+// DefaultEmailLimits lets a user ask again a couple of times when a message
+// is slow to arrive, without letting anyone flood an inbox.
+var DefaultEmailLimits = EmailLimits{
+	Window:     time.Hour,
+	PerAddress: 3,
+	PerIP:      20,
+}
+
+// EmailThrottle counts requested account emails per address and per client
+// IP in the same store as the login attempts.
+type EmailThrottle struct {
+	store  Store
+	limits EmailLimits
+	now    func() time.Time
+}
+
+func NewEmailThrottle(store Store, limits EmailLimits) *EmailThrottle {
+	return &EmailThrottle{store: store, limits: limits, now: time.Now}
+}
+
+func emailAddressKey(email string) string {
+	return "email:" + normalizeEmail(email)
+}
+
+func emailIPKey(ip string) string {
+	return "email-ip:" + ip
+}
+
+// Allow decides whether an email to address may be sent for a request from
+// ip and, if so, counts it against both. The response should not depend on
+// whether the address has an account, so callers check this first.
+func (t *EmailThrottle) Allow(ctx context.Context, address string, ip string) (Decision, error) {
+	now := t.now().Truncate(time.Millisecond)
+
+	decision, err := t.reserve(ctx, emailAddressKey(address), t.limits.PerAddress, now)
+	if err != nil || !decision.Allowed {
+		return decision, err
+	}
+
+	decision, err = t.reserve(ctx, emailIPKey(ip), t.limits.PerIP, now)
+	if err != nil || !decision.Allowed {
+		// Nothing is sent, so the address keeps its allowance.
+		if err := t.store.RemoveAttempt(ctx, emailAddressKey(address), now); err != nil {
+			log.Printf("Failed to release email request: %v", err)
+		}
+		return decision, err
+	}
+
+	return decision, nil
+}
+
+// Forget removes the requests counted for address.
+func (t *EmailThrottle) Forget(ctx context.Context, address string) error {
+	return t.store.Reset(ctx, emailAddressKey(address))
+}
+
+// reserve records a request for key unless it used up its limit within the
+// window, in which case the decision says when the oldest request that
+// counts leaves the window.
+func (t *EmailThrottle) reserve(ctx context.Context, key string, limit int, now time.Time) (Decision, error) {
+	for range maxReserveTries {
+		state, err := t.store.Get(ctx, key, now.Add(-t.limits.Window))
+		if err != nil {
+			return Decision{}, err
+		}
+
+		if n := len(state.Failures); n >= limit {
+			oldest := state.Failures[n-limit]
+			return Decision{RetryAfter: oldest.Add(t.limits.Window).Sub(now), Reason: "too_many_requests"}, nil
+		}
+
+		err = t.store.RecordAttempt(ctx, key, state.Version, now, t.limits.Window)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return Decision{}, err
+		}
+		return Decision{Allowed: true}, nil
+	}
+
+	return Decision{RetryAfter: time.Second, Reason: "too_many_requests"}, nil
+}
@@ -0,0 +1,68 @@
+package loginguard
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestEmailThrottle(limits EmailLimits) (*EmailThrottle, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)}
+	throttle := NewEmailThrottle(NewMemoryStore(), limits)
+	throttle.now = clock.now
+	return throttle, clock
+}
+
+func TestEmailThrottleLimitsEachAddress(t *testing.T) {
+	throttle, clock := newTestEmailThrottle(EmailLimits{Window: time.Hour, PerAddress: 3, PerIP: 100})
+	ctx := context.Background()
+
+	for i := range 3 {
+		decision, err := throttle.Allow(ctx, "jane@example.com", "203.0.113.7")
+		if err != nil || !decision.Allowed {
+			t.Fatalf("request %d = %+v, %v; want allowed", i+1, decision, err)
+		}
+		clock.advance(10 * time.Minute)
+	}
+
+	// The address is case-insensitive and the limit holds from another IP.
+	decision, err := throttle.Allow(ctx, " Jane@Example.com", "198.51.100.1")
+	if err != nil || decision.Allowed || decision.Reason != "too_many_requests" {
+		t.Fatalf("fourth request = %+v, %v; want too_many_requests", decision, err)
+	}
+	if decision.RetryAfter != 30*time.Minute {
+		t.Errorf("RetryAfter = %v, want 30m until the first request leaves the window", decision.RetryAfter)
+	}
+
+	if other, _ := throttle.Allow(ctx, "john@example.com", "203.0.113.7"); !other.Allowed {
+		t.Errorf("request for another address = %+v, want allowed", other)
+	}
+
+	clock.advance(decision.RetryAfter + time.Second)
+	if decision, _ := throttle.Allow(ctx, "jane@example.com", "203.0.113.7"); !decision.Allowed {
+		t.Errorf("request once the first left the window = %+v, want allowed", decision)
+	}
+}
+
+func TestEmailThrottleLimitsEachIP(t *testing.T) {
+	throttle, _ := newTestEmailThrottle(EmailLimits{Window: time.Hour, PerAddress: 3, PerIP: 2})
+	ctx := context.Background()
+
+	for _, address := range []string{"a@example.com", "b@example.com"} {
+		if decision, _ := throttle.Allow(ctx, address, "203.0.113.7"); !decision.Allowed {
+			t.Fatalf("request for %s = %+v, want allowed", address, decision)
+		}
+	}
+
+	decision, _ := throttle.Allow(ctx, "c@example.com", "203.0.113.7")
+	if decision.Allowed {
+		t.Fatal("third request from the IP was allowed")
+	}
+
+	// The rejected request does not use up the address's allowance.
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		if decision, _ := throttle.Allow(ctx, "c@example.com", ip); !decision.Allowed {
+			t.Fatalf("request for c@example.com from %s = %+v, want allowed", ip, decision)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+)
+
+// LogMailer writes messages instead of sending them, for local development.
+// With a Path the rendered messages are appended to that file, otherwise they
+// go to the standard logger.
+type LogMailer struct {
+	Path string
+	From string
+
+	mu sync.Mutex
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	from := m.From
+	if from == "" {
+		from = "MagicStream <no-reply@localhost>"
+	}
+	data := msg.format(from)
+
+	if m.Path == "" {
+		log.Printf("Email not sent (MAIL_TRANSPORT=log):\n%s", data)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,121 @@
+// Package mailer sends the account emails of the API, such as address
+// verification and password reset links.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultMailer Mailer = &LogMailer{}
+)
+
+// Load configures the mailer used by Send from the environment:
+//
+//	MAIL_TRANSPORT  smtp, file or log (default log)
+//	MAIL_FROM       sender address, optionally with a display name; required for smtp
+//	SMTP_HOST       SMTP server host, required for smtp
+//	SMTP_PORT       SMTP server port (default 587)
+//	SMTP_USERNAME   optional; enables authentication together with SMTP_PASSWORD
+//	SMTP_PASSWORD
+//	MAIL_FILE       file that file transport appends messages to
+//
+// It must be called after the environment is loaded.
+func Load() error {
+	var m Mailer
+	switch transport := os.Getenv("MAIL_TRANSPORT"); transport {
+	case "", "log":
+		m = &LogMailer{From: os.Getenv("MAIL_FROM")}
+	case "file":
+		path := os.Getenv("MAIL_FILE")
+		if path == "" {
+			return errors.New("MAIL_FILE is required for the file transport")
+		}
+		m = &LogMailer{Path: path, From: os.Getenv("MAIL_FROM")}
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		from := os.Getenv("MAIL_FROM")
+		if host == "" || from == "" {
+			return errors.New("SMTP_HOST and MAIL_FROM are required for the smtp transport")
+		}
+		if _, err := envelopeSender(from); err != nil {
+			return err
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		m = &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	default:
+		return fmt.Errorf("unknown MAIL_TRANSPORT %q", transport)
+	}
+
+	defaultMu.Lock()
+	defaultMailer = m
+	defaultMu.Unlock()
+	return nil
+}
+
+// Send delivers msg with the configured mailer.
+func Send(ctx context.Context, msg Message) error {
+	defaultMu.RLock()
+	m := defaultMailer
+	defaultMu.RUnlock()
+
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	return m.Send(ctx, msg)
+}
+
+// validate rejects line breaks in header values, which would let a caller
+// inject extra headers or recipients.
+func (m Message) validate() error {
+	if m.To == "" {
+		return errors.New("message has no recipient")
+	}
+	if strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return errors.New("message headers must not contain line breaks")
+	}
+	return nil
+}
+
+// format renders the message in RFC 5322 form with CRLF line endings.
+func (m Message) format(from string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,25 @@
+package mailer
+
+import "testing"
+
+func TestEnvelopeSender(t *testing.T) {
+	tests := []struct {
+		from string
+		want string
+	}{
+		{"no-reply@example.com", "no-reply@example.com"},
+		{"<no-reply@example.com>", "no-reply@example.com"},
+		{"Magic Stream <no-reply@example.com>", "no-reply@example.com"},
+		{`"Magic Stream" <no-reply@example.com>`, "no-reply@example.com"},
+	}
+	for _, tt := range tests {
+		got, err := envelopeSender(tt.from)
+		if err != nil || got != tt.want {
+			t.Errorf("envelopeSender(%q) = %q, %v; want %q", tt.from, got, err, tt.want)
+		}
+	}
+
+	if _, err := envelopeSender("Magic Stream"); err == nil {
+		t.Error("envelopeSender accepted a From without an address")
+	}
+}
@@ -2,21 +2,39 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/jobs"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/loginguard"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/mailer"
 	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/routes"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/similarity"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rerank" {
+		runRerankCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "similarity" {
+		runSimilarityCommand(os.Args[2:])
+		return
+	}
 
 	router := gin.Default()
 
@@ -29,6 +47,30 @@ func main() {
 		log.Println("Warning: unable to find .env file")
 	}
 
+	if err := utils.LoadSigningKeys(); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
+	if err := mailer.Load(); err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+
+	// Per-IP login limits use the client IP, which gin reads from
+	// X-Forwarded-For only for requests from a trusted proxy. No proxy is
+	// trusted unless TRUSTED_PROXIES lists them, so clients cannot spoof
+	// their address through the header.
+	var trustedProxies []string
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				trustedProxies = append(trustedProxies, p)
+			}
+		}
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
 
 	var origins []string
@@ -60,6 +102,18 @@ func main() {
 		log.Fatalf("Failed to reach server: %v", err)
 	}
 
+	if err := database.EnsureIndexes(client); err != nil {
+		log.Fatalf("Failed to create indexes: %v", err)
+	}
+
+	if err := database.RunMigrations(client); err != nil {
+		log.Fatalf("Failed to migrate data: %v", err)
+	}
+
+	if err := loginguard.Load(client); err != nil {
+		log.Fatalf("Failed to configure login guard: %v", err)
+	}
+
 	defer func(){
 		err := client.Disconnect(context.Background())
 		if err!= nil {
@@ -67,10 +121,47 @@ func main() {
 		}
 	}()
 
+	var rankingWorkers int64 = 2
+	rankingWorkersStr := os.Getenv("RANKING_WORKERS")
+	if rankingWorkersStr != "" {
+		parsed, err := strconv.ParseInt(rankingWorkersStr, 10, 64)
+		if err != nil || parsed < 1 {
+			log.Fatalf("Invalid RANKING_WORKERS %q", rankingWorkersStr)
+		}
+		rankingWorkers = parsed
+	}
+
+	// workerCtx lasts as long as the server: it is cancelled on SIGINT or
+	// SIGTERM, which stops the workers and any rerank run in progress.
+	workerCtx, stopWorkers := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopWorkers()
+	jobs.StartWorkers(workerCtx, client, int(rankingWorkers))
+
+	// Similarities can also be computed on demand with the similarity
+	// subcommand, e.g. from cron, in which case leave this unset.
+	if v := os.Getenv("SIMILARITY_REFRESH_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			log.Fatalf("Invalid SIMILARITY_REFRESH_INTERVAL %q", v)
+		}
+		similarity.StartScheduler(workerCtx, client, interval, similarity.Options{})
+	}
+
 	routes.SetupUnprotectedRoutes(router, client)
-	routes.SetupProtectedRoutes(router, client)
+	routes.SetupProtectedRoutes(workerCtx, router, client)
+
+	server := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Println("Failed to start server", err)
+			stopWorkers()
+		}
+	}()
 
-	if err := router.Run(":8080"); err != nil {
-		fmt.Println("Failed to start server", err)
+	<-workerCtx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
 	}
 }
@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"errors"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
 	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token, err := utils.GetAccessToken(c)
 		if err != nil {
@@ -18,14 +23,110 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if utils.IsAPIKey(token) {
+			authenticateAPIKey(c, client, token)
+			return
+		}
 		claims, err := utils.ValidateToken(token)
 		if err != nil {
 			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
+		revoked, err := isRevoked(c, client, claims)
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"error": "Error checking token"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Token has been revoked"})
+			return
+		}
 		c.Set("userId", claims.UserId)
 		c.Set("role", claims.Role)
+		c.Set("sessionId", claims.SessionId)
+		c.Set("accessToken", models.IssuedToken{ID: claims.ID, ExpiresAt: claims.ExpiresAt.Time})
+
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware identifies the caller when a valid access token is
+// present but lets anonymous requests through, for public routes that
+// personalise their response for signed-in users.
+func OptionalAuthMiddleware(client *mongo.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := utils.GetAccessToken(c)
+		if err == nil && utils.IsAPIKey(token) {
+			if apiKey, role, err := utils.AuthenticateAPIKey(c, client, token); err == nil && scopeAllows(apiKey.Scope, c.Request.Method) {
+				c.Set("userId", apiKey.UserID)
+				c.Set("role", role)
+				c.Set("apiKeyId", apiKey.ID.Hex())
+			}
+		} else if err == nil && token != "" {
+			if claims, err := utils.ValidateToken(token); err == nil {
+				if revoked, err := isRevoked(c, client, claims); err == nil && !revoked {
+					c.Set("userId", claims.UserId)
+					c.Set("role", claims.Role)
+				}
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// isRevoked reports whether the token was revoked before its expiry, by logout
+// or by ending its session. Tokens issued without a jti cannot be revoked.
+func isRevoked(c *gin.Context, client *mongo.Client, claims *utils.SignedDetails) (bool, error) {
+	if claims.ID == "" {
+		return false, nil
+	}
+	return utils.IsTokenRevoked(c, client, claims.ID)
+}
+
+// authenticateAPIKey identifies the caller from an API key and enforces its
+// scope: read-only keys may only use safe methods, admin keys act with the
+// full rights of their owner.
+func authenticateAPIKey(c *gin.Context, client *mongo.Client, key string) {
+	apiKey, role, err := utils.AuthenticateAPIKey(c, client, key)
+	if errors.Is(err, utils.ErrAPIKeyNotFound) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid, revoked or expired API key"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error checking API key"})
+		return
+	}
+
+	if !scopeAllows(apiKey.Scope, c.Request.Method) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key is read-only"})
+		return
+	}
+
+	c.Set("userId", apiKey.UserID)
+	c.Set("role", role)
+	c.Set("apiKeyId", apiKey.ID.Hex())
+
+	c.Next()
+}
+
+func scopeAllows(scope string, method string) bool {
+	if scope == models.APIKeyScopeAdmin {
+		return true
+	}
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// RequireSession rejects requests authenticated with an API key, for account
+// management that must stay behind an interactive login, such as creating
+// further keys or changing the password. It must run after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := utils.GetAPIKeyIdFromContext(c); err == nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used for this resource"})
+			return
+		}
 
 		c.Next()
 	}
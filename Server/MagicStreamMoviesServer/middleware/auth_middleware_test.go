@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// testClient connects to the MongoDB named by MONGODB_TEST_URI and points
+// OpenCollection at a throwaway database.
+func testClient(t *testing.T) *mongo.Client {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	databaseName := fmt.Sprintf("magicstream_test_%d", time.Now().UnixNano())
+	t.Setenv("DATABASE_NAME", databaseName)
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_ = client.Database(databaseName).Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	return client
+}
+
+// testRouter serves the caller's identity behind AuthMiddleware, behind
+// AuthMiddleware plus RequireSession, and behind OptionalAuthMiddleware.
+func testRouter(client *mongo.Client) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	whoami := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("userId"), "role": c.GetString("role"), "api_key_id": c.GetString("apiKeyId")})
+	}
+	router.Any("/private", AuthMiddleware(client), whoami)
+	router.Any("/session", AuthMiddleware(client), RequireSession(), whoami)
+	router.Any("/public", OptionalAuthMiddleware(client), whoami)
+
+	return router
+}
+
+func serve(router *gin.Engine, method string, path string, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		scope  string
+		method string
+		want   bool
+	}{
+		{models.APIKeyScopeReadOnly, http.MethodGet, true},
+		{models.APIKeyScopeReadOnly, http.MethodHead, true},
+		{models.APIKeyScopeReadOnly, http.MethodOptions, true},
+		{models.APIKeyScopeReadOnly, http.MethodPost, false},
+		{models.APIKeyScopeReadOnly, http.MethodDelete, false},
+		{models.APIKeyScopeAdmin, http.MethodDelete, true},
+		{"", http.MethodPatch, false},
+	}
+	for _, tt := range tests {
+		if got := scopeAllows(tt.scope, tt.method); got != tt.want {
+			t.Errorf("scopeAllows(%q, %s) = %v, want %v", tt.scope, tt.method, got, tt.want)
+		}
+	}
+}
+
+// Credentials that cannot be read as a bearer token are rejected before any
+// lookup, so no database is needed.
+func TestAuthMiddlewareRejectsMalformedCredentials(t *testing.T) {
+	router := testRouter(nil)
+
+	for _, authorization := range []string{"msk_abc", "Basic msk_abc", "Bearer ", "Bearer"} {
+		if w := serve(router, http.MethodGet, "/private", authorization); w.Code != http.StatusUnauthorized {
+			t.Errorf("%q: status %d, want %d", authorization, w.Code, http.StatusUnauthorized)
+		}
+		if w := serve(router, http.MethodGet, "/public", authorization); w.Code != http.StatusOK || w.Body.String() != `{"api_key_id":"","role":"","user_id":""}` {
+			t.Errorf("%q on an optional route: %d %s", authorization, w.Code, w.Body)
+		}
+	}
+}
+
+func TestAuthMiddlewareAPIKeys(t *testing.T) {
+	client := testClient(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := database.OpenCollection("users", client).InsertOne(ctx, bson.M{"user_id": "u1", "role": "USER"}); err != nil {
+		t.Fatal(err)
+	}
+
+	apiKeyCollection := database.OpenCollection("api_keys", client)
+	keys := map[string]string{}
+	ids := map[string]string{}
+	insert := func(name string, scope string, mutate func(*models.APIKey)) {
+		key, prefix, err := utils.GenerateAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		apiKey := models.APIKey{
+			ID:        bson.NewObjectID(),
+			UserID:    "u1",
+			Name:      name,
+			Scope:     scope,
+			KeyHash:   utils.HashToken(key),
+			Prefix:    prefix,
+			CreatedAt: now,
+		}
+		if mutate != nil {
+			mutate(&apiKey)
+		}
+		if _, err := apiKeyCollection.InsertOne(ctx, apiKey); err != nil {
+			t.Fatal(err)
+		}
+		keys[name], ids[name] = key, apiKey.ID.Hex()
+	}
+
+	revokedAt, expiredAt := now.Add(-time.Minute), now.Add(-time.Second)
+	insert("read", models.APIKeyScopeReadOnly, nil)
+	insert("admin", models.APIKeyScopeAdmin, nil)
+	insert("revoked", models.APIKeyScopeAdmin, func(k *models.APIKey) { k.RevokedAt = &revokedAt })
+	insert("expired", models.APIKeyScopeAdmin, func(k *models.APIKey) { k.ExpiresAt = &expiredAt })
+
+	router := testRouter(client)
+	identity := func(name string) string {
+		return fmt.Sprintf(`{"api_key_id":%q,"role":"USER","user_id":"u1"}`, ids[name])
+	}
+	const anonymous = `{"api_key_id":"","role":"","user_id":""}`
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		credential string
+		wantCode   int
+		wantBody   string
+	}{
+		{"valid read-only key", http.MethodGet, "/private", keys["read"], http.StatusOK, identity("read")},
+		{"read-only key writing", http.MethodPost, "/private", keys["read"], http.StatusForbidden, ""},
+		{"valid admin key", http.MethodPost, "/private", keys["admin"], http.StatusOK, identity("admin")},
+		{"revoked key", http.MethodGet, "/private", keys["revoked"], http.StatusUnauthorized, ""},
+		{"expired key", http.MethodGet, "/private", keys["expired"], http.StatusUnauthorized, ""},
+		{"malformed key", http.MethodGet, "/private", "msk_not-a-key", http.StatusUnauthorized, ""},
+		{"key on a session-only route", http.MethodGet, "/session", keys["admin"], http.StatusForbidden, ""},
+		{"optional route, valid key", http.MethodGet, "/public", keys["read"], http.StatusOK, identity("read")},
+		{"optional route, read-only key writing", http.MethodPost, "/public", keys["read"], http.StatusOK, anonymous},
+		{"optional route, revoked key", http.MethodGet, "/public", keys["revoked"], http.StatusOK, anonymous},
+		{"optional route, expired key", http.MethodGet, "/public", keys["expired"], http.StatusOK, anonymous},
+		{"optional route, malformed key", http.MethodGet, "/public", "msk_not-a-key", http.StatusOK, anonymous},
+	}
+	for _, tt := range tests {
+		w := serve(router, tt.method, tt.path, "Bearer "+tt.credential)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, w.Code, tt.wantCode, w.Body)
+			continue
+		}
+		if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+			t.Errorf("%s: body %s, want %s", tt.name, w.Body, tt.wantBody)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/utils"
+)
+
+const (
+	PermissionMoviesWrite  = "movies:write"
+	PermissionReviewsWrite = "reviews:write"
+)
+
+// rolePermissions lists what each role is allowed to do beyond reading.
+var rolePermissions = map[string][]string{
+	"ADMIN": {PermissionMoviesWrite, PermissionReviewsWrite},
+	"USER":  {},
+}
+
+// RequireRole only lets the request through when the authenticated user has
+// one of the given roles. It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := utils.GetRoleFromContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Role not found in context"})
+			return
+		}
+
+		if !slices.Contains(roles, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient role for this resource"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission only lets the request through when the user's role grants
+// the given permission. It must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := utils.GetRoleFromContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Role not found in context"})
+			return
+		}
+
+		if !slices.Contains(rolePermissions[role], permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing permission " + permission})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	APIKeyScopeReadOnly = "read-only"
+	APIKeyScopeAdmin    = "admin"
+)
+
+// APIKey is a long-lived credential for scripts and service integrations. The
+// key itself is only shown once, at creation; a hash is stored and the prefix
+// is kept so that users can tell their keys apart.
+type APIKey struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"key_id"`
+	UserID     string        `bson:"user_id" json:"user_id"`
+	Name       string        `bson:"name" json:"name"`
+	Scope      string        `bson:"scope" json:"scope"`
+	KeyHash    string        `bson:"key_hash" json:"-"`
+	Prefix     string        `bson:"prefix" json:"prefix"`
+	CreatedAt  time.Time     `bson:"created_at" json:"created_at"`
+	LastUsedAt *time.Time    `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time    `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	RevokedAt  *time.Time    `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+type APIKeyCreate struct {
+	Name      string     `json:"name" validate:"required,min=1,max=100"`
+	Scope     string     `json:"scope" validate:"required,oneof=read-only admin"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey is returned once when a key is created and is the only
+// response that contains the key.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// WatchHistoryEntry records that a user watched a movie. There is one entry
+// per user and movie; watching again bumps WatchCount and WatchedAt.
+type WatchHistoryEntry struct {
+	ID             bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID         string        `bson:"user_id" json:"user_id"`
+	ImdbID         string        `bson:"imdb_id" json:"imdb_id"`
+	WatchCount     int           `bson:"watch_count" json:"watch_count"`
+	FirstWatchedAt time.Time     `bson:"first_watched_at" json:"first_watched_at"`
+	WatchedAt      time.Time     `bson:"watched_at" json:"watched_at"`
+	Movie          *Movie        `bson:"movie,omitempty" json:"movie,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	JobStatusPending    = "pending"
+	JobStatusRunning    = "running"
+	JobStatusDone       = "done"
+	JobStatusFailed     = "failed"
+	JobStatusSuperseded = "superseded"
+)
+
+const (
+	JobTypeRankReview      = "rank_review"
+	JobTypeLabelUserReview = "label_user_review"
+)
+
+// JobErrorUnrecognizedRanking is the error code of a job that failed because
+// the model never answered with one of the allowed rankings.
+const JobErrorUnrecognizedRanking = "unrecognized_ranking"
+
+type Job struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Type        string        `bson:"type" json:"type"`
+	ImdbID      string        `bson:"imdb_id" json:"imdb_id"`
+	ReviewID    bson.ObjectID `bson:"review_id,omitempty" json:"review_id,omitempty"`
+	Review      string        `bson:"review" json:"review"`
+	Status      string        `bson:"status" json:"status"`
+	Attempts    int           `bson:"attempts" json:"attempts"`
+	MaxAttempts int           `bson:"max_attempts" json:"max_attempts"`
+	RunAt       time.Time     `bson:"run_at" json:"run_at"`
+	LockedUntil time.Time     `bson:"locked_until,omitempty" json:"-"`
+	LastError   string        `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt   time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time     `bson:"updated_at" json:"updated_at"`
+	// ErrorCode and AllowedRankings describe why a failed job cannot succeed
+	// by being retried.
+	ErrorCode       string   `bson:"error_code,omitempty" json:"error_code,omitempty"`
+	AllowedRankings []string `bson:"allowed_rankings,omitempty" json:"allowed_rankings,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	LoginAuditLockout = "lockout"
+	LoginAuditUnlock  = "unlock"
+
+	LoginScopeAccount = "account"
+	LoginScopeIP      = "ip"
+)
+
+// LoginAuditEvent records an account or client IP being locked out after
+// repeated failed logins, or an admin lifting such a lockout.
+type LoginAuditEvent struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"event_id"`
+	Type        string        `bson:"type" json:"type"`
+	Scope       string        `bson:"scope" json:"scope"`
+	Subject     string        `bson:"subject" json:"subject"`
+	IP          string        `bson:"ip,omitempty" json:"ip,omitempty"`
+	Failures    int           `bson:"failures,omitempty" json:"failures,omitempty"`
+	LockedUntil *time.Time    `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	ActorID     string        `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	CreatedAt   time.Time     `bson:"created_at" json:"created_at"`
+}
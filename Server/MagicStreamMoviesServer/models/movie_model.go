@@ -1,9 +1,17 @@
 package models
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+const (
+	RankingStatusPending = "pending"
+	RankingStatusRanked  = "ranked"
+	RankingStatusFailed  = "failed"
+)
+
 type Genre struct {
 	GenreId   int    `bson:"genre_id" json:"genre_id" validate:"required"`
 	GenreName string `bson:"genre_name" json:"genre_name" validate:"required,min=2,max=100"`
@@ -14,13 +22,95 @@ type Ranking struct {
 	RankingName  string `bson:"ranking_name" json:"ranking_name" validate:"required"`
 }
 
+// RankingDefinition is a document of the rankings collection. Movies embed
+// only the Ranking part of it.
+type RankingDefinition struct {
+	RankingValue   int    `bson:"ranking_value" json:"ranking_value" validate:"required"`
+	RankingName    string `bson:"ranking_name" json:"ranking_name" validate:"required,min=2,max=50"`
+	SelectableByAI bool   `bson:"selectable_by_ai" json:"selectable_by_ai"`
+	Color          string `bson:"color,omitempty" json:"color,omitempty" validate:"omitempty,hexcolor"`
+}
+
+func (d RankingDefinition) Ranking() Ranking {
+	return Ranking{RankingValue: d.RankingValue, RankingName: d.RankingName}
+}
+
 type Movie struct {
-	ID          bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
-	ImdbID      string        `bson:"imdb_id" json:"imdb_id" validate:"required"`
-	Title       string        `bson:"title" json:"title" validate:"required,min=2,max=500"`
-	PosterPath  string        `bson:"poster_path" json:"poster_path" validate:"required,url"`
-	YouTubeID   string        `bson:"youtube_id" json:"youtube_id" validate:"required"`
-	Genre       []Genre       `bson:"genre" json:"genre" validate:"required,dive"`
-	AdminReview string        `bson:"admin_review" json:"admin_review"`
-	Ranking     Ranking       `bson:"ranking" json:"ranking" validate:"required"`
+	ID            bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	ImdbID        string        `bson:"imdb_id" json:"imdb_id" validate:"required"`
+	Title         string        `bson:"title" json:"title" validate:"required,min=2,max=500"`
+	PosterPath    string        `bson:"poster_path" json:"poster_path" validate:"required,url"`
+	YouTubeID     string        `bson:"youtube_id" json:"youtube_id" validate:"required"`
+	Genre         []Genre       `bson:"genre" json:"genre" validate:"required,dive"`
+	AdminReview   string        `bson:"admin_review" json:"admin_review"`
+	Directors     []string      `bson:"directors" json:"directors" validate:"max=20,dive,min=2,max=200"`
+	Cast          []string      `bson:"cast" json:"cast" validate:"max=100,dive,min=2,max=200"`
+	Ranking       Ranking       `bson:"ranking" json:"ranking" validate:"required"`
+	DeletedAt     *time.Time    `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	RankingStatus string        `bson:"ranking_status,omitempty" json:"ranking_status,omitempty"`
+	RatingAverage float64       `bson:"rating_average" json:"rating_average"`
+	RatingCount   int           `bson:"rating_count" json:"rating_count"`
+	RatingSum     int           `bson:"rating_sum" json:"-"`
+	InWatchlist   *bool         `bson:"-" json:"in_watchlist,omitempty"`
+}
+
+// ResetServerFields clears the fields clients may not set when creating a
+// movie.
+func (m *Movie) ResetServerFields() {
+	m.ID = bson.ObjectID{}
+	m.DeletedAt = nil
+	m.RankingStatus = ""
+	m.RatingAverage = 0
+	m.RatingCount = 0
+	m.RatingSum = 0
+}
+
+type Rating struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID    string        `bson:"user_id" json:"user_id"`
+	ImdbID    string        `bson:"imdb_id" json:"imdb_id"`
+	Stars     int           `bson:"stars" json:"stars" validate:"required,min=1,max=5"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+// MoviePatch holds the fields accepted by a partial movie update. Nil fields
+// are left untouched; the rest are validated with the same rules as Movie.
+// The tags use omitnil rather than omitempty so that a field sent as "" or []
+// is still validated and rejected instead of being stored.
+type MoviePatch struct {
+	Title       *string   `json:"title" validate:"omitnil,min=2,max=500"`
+	PosterPath  *string   `json:"poster_path" validate:"omitnil,url"`
+	YouTubeID   *string   `json:"youtube_id" validate:"omitnil,min=1"`
+	Genre       *[]Genre  `json:"genre" validate:"omitnil,min=1,dive"`
+	AdminReview *string   `json:"admin_review"`
+	Directors   *[]string `json:"directors" validate:"omitnil,max=20,dive,min=2,max=200"`
+	Cast        *[]string `json:"cast" validate:"omitnil,max=100,dive,min=2,max=200"`
+	Ranking     *Ranking  `json:"ranking"`
+}
+
+type BulkMovieResult struct {
+	Row    int    `json:"row"`
+	ImdbID string `json:"imdb_id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type MovieListResponse struct {
+	Movies     []Movie `json:"movies"`
+	Total      int64   `json:"total"`
+	Page       int64   `json:"page"`
+	PageSize   int64   `json:"page_size"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+type SearchHighlight struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+type MovieSearchResult struct {
+	Movie      Movie             `json:"movie"`
+	Score      float64           `json:"score"`
+	Highlights []SearchHighlight `json:"highlights"`
 }
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestMoviePatchValidatesEmptyValues(t *testing.T) {
+	validate := validator.New()
+	empty := ""
+	noGenres := []Genre{}
+	title := "Heat"
+
+	tests := []struct {
+		name  string
+		patch MoviePatch
+		valid bool
+	}{
+		{"no fields", MoviePatch{}, true},
+		{"title", MoviePatch{Title: &title}, true},
+		{"empty title", MoviePatch{Title: &empty}, false},
+		{"empty YouTube id", MoviePatch{YouTubeID: &empty}, false},
+		{"empty poster path", MoviePatch{PosterPath: &empty}, false},
+		{"no genres", MoviePatch{Genre: &noGenres}, false},
+		{"empty admin review", MoviePatch{AdminReview: &empty}, true},
+	}
+	for _, tt := range tests {
+		if err := validate.Struct(tt.patch); (err == nil) != tt.valid {
+			t.Errorf("%s: validation error %v, want valid=%v", tt.name, err, tt.valid)
+		}
+	}
+}
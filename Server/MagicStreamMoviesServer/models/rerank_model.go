@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	RerankStatusRunning     = "running"
+	RerankStatusCompleted   = "completed"
+	RerankStatusInterrupted = "interrupted"
+	RerankStatusFailed      = "failed"
+)
+
+// RerankRun records the progress of a catalog re-ranking so that an
+// interrupted run can resume after LastImdbID.
+type RerankRun struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Status      string        `bson:"status" json:"status"`
+	DryRun      bool          `bson:"dry_run" json:"dry_run"`
+	Concurrency int           `bson:"concurrency" json:"concurrency"`
+	LastImdbID  string        `bson:"last_imdb_id" json:"last_imdb_id"`
+	Processed   int           `bson:"processed" json:"processed"`
+	Changed     int           `bson:"changed" json:"changed"`
+	Failed      int           `bson:"failed" json:"failed"`
+	Error       string        `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt   time.Time     `bson:"started_at" json:"started_at"`
+	UpdatedAt   time.Time     `bson:"updated_at" json:"updated_at"`
+	HeartbeatAt time.Time     `bson:"heartbeat_at" json:"heartbeat_at"`
+	FinishedAt  *time.Time    `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// RerankChange is a movie whose ranking a run changed or failed to compute.
+// Changes are stored in the rerank_changes collection rather than on the run,
+// which would otherwise outgrow the document size limit on a large catalog.
+type RerankChange struct {
+	RunID  bson.ObjectID `bson:"run_id" json:"-"`
+	ImdbID string        `bson:"imdb_id" json:"imdb_id"`
+	Title  string        `bson:"title" json:"title"`
+	Old    Ranking       `bson:"old" json:"old"`
+	New    Ranking       `bson:"new" json:"new"`
+	Error  string        `bson:"error,omitempty" json:"error,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	UserReviewStatusPending  = "pending"
+	UserReviewStatusApproved = "approved"
+	UserReviewStatusHidden   = "hidden"
+)
+
+// UserReview is a review written by a regular user. Only approved reviews are
+// listed publicly. Sentiment is filled in asynchronously by the same
+// classifier that ranks admin reviews; SentimentStatus uses the RankingStatus
+// values.
+type UserReview struct {
+	ID              bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID          string        `bson:"user_id" json:"user_id"`
+	ImdbID          string        `bson:"imdb_id" json:"imdb_id"`
+	Text            string        `bson:"text" json:"text" validate:"required,min=2,max=5000"`
+	Status          string        `bson:"status" json:"status"`
+	Sentiment       *Ranking      `bson:"sentiment,omitempty" json:"sentiment,omitempty"`
+	SentimentStatus string        `bson:"sentiment_status" json:"sentiment_status"`
+	ModeratedBy     string        `bson:"moderated_by,omitempty" json:"moderated_by,omitempty"`
+	ModeratedAt     *time.Time    `bson:"moderated_at,omitempty" json:"moderated_at,omitempty"`
+	CreatedAt       time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time     `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Session is one signed-in device. It starts at login and follows every
+// rotated refresh token issued from it, so revoking it logs out the whole
+// token family. Only a hash of the current refresh token is stored.
+type Session struct {
+	ID               bson.ObjectID `bson:"_id,omitempty" json:"session_id"`
+	UserID           string        `bson:"user_id" json:"user_id"`
+	RefreshTokenHash string        `bson:"refresh_token_hash" json:"-"`
+	UserAgent        string        `bson:"user_agent" json:"user_agent"`
+	Device           string        `bson:"device" json:"device"`
+	IP               string        `bson:"ip" json:"ip"`
+	CreatedAt        time.Time     `bson:"created_at" json:"created_at"`
+	LastUsedAt       time.Time     `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt        time.Time     `bson:"expires_at" json:"expires_at"`
+	RevokedAt        *time.Time    `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokedReason    string        `bson:"revoked_reason,omitempty" json:"revoked_reason,omitempty"`
+	// AccessTokens lists the access tokens issued for the session, so that
+	// they can be denied when it is revoked.
+	AccessTokens []IssuedToken `bson:"access_tokens" json:"-"`
+	// PreviousRefreshTokenHash is the token replaced at RotatedAt. It is
+	// still recognised for a moment so that concurrent refreshes from tabs
+	// sharing the cookie are not taken for reuse.
+	PreviousRefreshTokenHash string     `bson:"previous_refresh_token_hash,omitempty" json:"-"`
+	RotatedAt                *time.Time `bson:"rotated_at,omitempty" json:"-"`
+	Current                  bool       `bson:"-" json:"current"`
+}
+
+// IssuedToken identifies a signed token by its jti claim.
+type IssuedToken struct {
+	ID        string    `bson:"jti" json:"jti"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+}
+
+// RevokedToken is an entry of the token denylist. Entries are removed by a
+// TTL index once the token would have expired anyway.
+type RevokedToken struct {
+	ID        string    `bson:"jti" json:"jti"`
+	SessionID string    `bson:"session_id,omitempty" json:"session_id,omitempty"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+	RevokedAt time.Time `bson:"revoked_at" json:"revoked_at"`
+}
+
+const (
+	SessionRevokedLogout         = "logout"
+	SessionRevokedByUser         = "revoked_by_user"
+	SessionRevokedReuse          = "refresh_token_reuse"
+	SessionRevokedPasswordChange = "password_changed"
+	SessionRevokedPasswordReset  = "password_reset"
+	SessionRevokedAccountDeleted = "account_deleted"
+)
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// MovieSimilarity holds the precomputed nearest neighbours of a movie, best
+// first, as found by the item-to-item collaborative filtering job.
+type MovieSimilarity struct {
+	ImdbID     string      `bson:"imdb_id" json:"imdb_id"`
+	Neighbours []Neighbour `bson:"neighbours" json:"neighbours"`
+	ComputedAt time.Time   `bson:"computed_at" json:"computed_at"`
+}
+
+type Neighbour struct {
+	ImdbID string  `bson:"imdb_id" json:"imdb_id"`
+	Score  float64 `bson:"score" json:"score"`
+	// CoUsers is how many users interacted with both movies.
+	CoUsers int `bson:"co_users" json:"co_users"`
+}
+
+type SimilarMovie struct {
+	Movie   Movie   `json:"movie"`
+	Score   float64 `json:"score"`
+	CoUsers int     `json:"co_users"`
+}
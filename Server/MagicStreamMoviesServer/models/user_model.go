@@ -7,18 +7,30 @@ import (
 )
 
 type User struct {
-	ID             bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
-	UserID         string        `bson:"user_id" json:"user_id"`
-	FirstName      string        `bson:"first_name" json:"first_name" validate:"required,min=2,max=100"`
-	LastName       string        `bson:"last_name" json:"last_name" validate:"required,min=2,max=100"`
-	Email          string        `bson:"email" json:"email" validate:"required,email"`
-	Password       string        `bson:"password" json:"password" validate:"required,min=6"`
-	Role           string        `bson:"role" json:"role" validate:"oneof=ADMIN USER"`
-	CreatedAt      time.Time     `bson:"created_at" json:"created_at"`
-	UpdatedAt      time.Time     `bson:"updated_at" json:"updated_at"`
-	Token          string        `bson:"token" json:"token"`
-	RefreshToken   string        `bson:"refresh_token" json:"refresh_token"`
-	FavoriteGenres []Genre       `bson:"favorite_genres" json:"favorite_genres" validate:"dive"`
+	ID                bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID            string        `bson:"user_id" json:"user_id"`
+	FirstName         string        `bson:"first_name" json:"first_name" validate:"required,min=2,max=100"`
+	LastName          string        `bson:"last_name" json:"last_name" validate:"required,min=2,max=100"`
+	Email             string        `bson:"email" json:"email" validate:"required,email"`
+	Password          string        `bson:"password" json:"password" validate:"required,min=6"`
+	Role              string        `bson:"role" json:"role" validate:"oneof=ADMIN USER"`
+	EmailVerified     bool          `bson:"email_verified" json:"email_verified"`
+	PendingEmail      string        `bson:"pending_email,omitempty" json:"pending_email,omitempty"`
+	CreatedAt         time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time     `bson:"updated_at" json:"updated_at"`
+	FavoriteGenres    []Genre       `bson:"favorite_genres" json:"favorite_genres" validate:"dive"`
+	FavoriteDirectors []string      `bson:"favorite_directors" json:"favorite_directors" validate:"max=50,dive,min=2,max=200"`
+	FavoriteActors    []string      `bson:"favorite_actors" json:"favorite_actors" validate:"max=50,dive,min=2,max=200"`
+}
+
+// UserPatch holds the profile fields a user may change themselves. Nil
+// fields are left untouched; the rest follow the same rules as User.
+// Changing the email also needs the current password.
+type UserPatch struct {
+	FirstName       *string `json:"first_name" validate:"omitempty,min=2,max=100"`
+	LastName        *string `json:"last_name" validate:"omitempty,min=2,max=100"`
+	Email           *string `json:"email" validate:"omitempty,email"`
+	CurrentPassword string  `json:"current_password" validate:"required_with=Email"`
 }
 
 type UserLogin struct {
@@ -27,12 +39,16 @@ type UserLogin struct {
 }
 
 type UserResponse struct {
-	UserID         string  `json:"user_id"`
-	FirstName      string  `json:"first_name"`
-	LastName       string  `json:"last_name"`
-	Email          string  `json:"email"`
-	Role           string  `json:"role"`
-	Token          string  `json:"token"`
-	RefreshToken   string  `json:"refresh_token"`
-	FavoriteGenres []Genre `json:"favorite_genres"`
+	UserID            string   `json:"user_id"`
+	FirstName         string   `json:"first_name"`
+	LastName          string   `json:"last_name"`
+	Email             string   `json:"email"`
+	Role              string   `json:"role"`
+	EmailVerified     bool     `json:"email_verified"`
+	PendingEmail      string   `json:"pending_email,omitempty"`
+	Token             string   `json:"token"`
+	RefreshToken      string   `json:"refresh_token"`
+	FavoriteGenres    []Genre  `json:"favorite_genres"`
+	FavoriteDirectors []string `json:"favorite_directors"`
+	FavoriteActors    []string `json:"favorite_actors"`
 }
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	UserTokenVerifyEmail   = "verify_email"
+	UserTokenPasswordReset = "password_reset"
+)
+
+// UserToken is a single-use token mailed to a user, for verifying an email
+// address or resetting a forgotten password. Only its hash is stored.
+type UserToken struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID    string        `bson:"user_id" json:"user_id"`
+	Purpose   string        `bson:"purpose" json:"purpose"`
+	TokenHash string        `bson:"token_hash" json:"-"`
+	// Email is the address the token was sent to. For verification it is
+	// the address being confirmed, which may be a pending change.
+	Email     string     `bson:"email" json:"email"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	ExpiresAt time.Time  `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time `bson:"used_at,omitempty" json:"used_at,omitempty"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type PasswordResetRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6"`
+}
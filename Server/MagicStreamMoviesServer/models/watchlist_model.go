@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// WatchlistEntry is a movie a user saved for later. Position orders the
+// user's watchlist; new entries are appended at the end.
+type WatchlistEntry struct {
+	ID       bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID   string        `bson:"user_id" json:"user_id"`
+	ImdbID   string        `bson:"imdb_id" json:"imdb_id"`
+	Position int           `bson:"position" json:"position"`
+	AddedAt  time.Time     `bson:"added_at" json:"added_at"`
+}
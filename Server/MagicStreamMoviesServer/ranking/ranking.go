@@ -0,0 +1,150 @@
+// Package ranking loads the rankings collection and ranks reviews against it
+// with the configured review classifier.
+package ranking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/classifier"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func LoadRankings(ctx context.Context, client *mongo.Client) ([]models.RankingDefinition, error) {
+	var rankings []models.RankingDefinition
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Second)
+	defer cancel()
+
+	var rankingCollection *mongo.Collection = database.OpenCollection("rankings", client)
+
+	opts := options.Find().SetSort(bson.D{{Key: "ranking_value", Value: 1}})
+
+	cursor, err := rankingCollection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &rankings); err != nil {
+		return nil, err
+	}
+
+	return rankings, nil
+}
+
+// ErrUnknownRanking is returned by Check for a ranking that is not in the
+// rankings collection.
+var ErrUnknownRanking = errors.New("unknown ranking")
+
+// Claim returns the rankings with the given values, keyed by value, and bumps
+// their version. It must run in the transaction that saves one of them into a
+// movie or review: the version write makes that transaction conflict with a
+// concurrent rename or delete of the ranking, so one of the two is retried
+// after the other commits instead of both committing against a stale
+// snapshot.
+func Claim(ctx context.Context, client *mongo.Client, values []int) (map[int]models.RankingDefinition, error) {
+	known := map[int]models.RankingDefinition{}
+	if len(values) == 0 {
+		return known, nil
+	}
+
+	var rankingCollection *mongo.Collection = database.OpenCollection("rankings", client)
+
+	filter := bson.M{"ranking_value": bson.M{"$in": values}}
+	if _, err := rankingCollection.UpdateMany(ctx, filter, bson.M{"$inc": bson.M{"version": 1}}); err != nil {
+		return nil, err
+	}
+
+	cursor, err := rankingCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var rankings []models.RankingDefinition
+	if err := cursor.All(ctx, &rankings); err != nil {
+		return nil, err
+	}
+
+	for _, r := range rankings {
+		known[r.RankingValue] = r
+	}
+	return known, nil
+}
+
+// Check reports ErrUnknownRanking unless r matches one of known by both value
+// and name.
+func Check(r models.Ranking, known map[int]models.RankingDefinition) error {
+	if def, ok := known[r.RankingValue]; !ok || def.RankingName != r.RankingName {
+		return fmt.Errorf("%w: %d %q", ErrUnknownRanking, r.RankingValue, r.RankingName)
+	}
+	return nil
+}
+
+// Resolve is Claim and Check for a single ranking.
+func Resolve(ctx context.Context, client *mongo.Client, r models.Ranking) error {
+	known, err := Claim(ctx, client, []int{r.RankingValue})
+	if err != nil {
+		return err
+	}
+	return Check(r, known)
+}
+
+// Selectable keeps the rankings flagged as selectable_by_ai, dropping
+// placeholders such as Not_Ranked that a classifier must never pick.
+func Selectable(rankings []models.RankingDefinition) []models.Ranking {
+	var selectable []models.Ranking
+	for _, r := range rankings {
+		if r.SelectableByAI {
+			selectable = append(selectable, r.Ranking())
+		}
+	}
+	return selectable
+}
+
+// Ranker classifies reviews against a snapshot of the rankings collection.
+// Reuse one Ranker when ranking many reviews to avoid reloading rankings and
+// rebuilding the classifier for each of them.
+type Ranker struct {
+	classifier classifier.ReviewClassifier
+	rankings   []models.Ranking
+}
+
+func NewRanker(ctx context.Context, client *mongo.Client) (*Ranker, error) {
+	rankings, err := LoadRankings(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	reviewClassifier, err := classifier.NewFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	selectable := Selectable(rankings)
+	if len(selectable) == 0 {
+		return nil, errors.New("no rankings are selectable_by_ai")
+	}
+
+	return &Ranker{classifier: reviewClassifier, rankings: selectable}, nil
+}
+
+func (r *Ranker) Rank(ctx context.Context, review string) (models.Ranking, error) {
+	return r.classifier.Classify(ctx, review, r.rankings)
+}
+
+// RankReview classifies review with the classifier configured in the
+// environment and returns the matching ranking.
+func RankReview(ctx context.Context, client *mongo.Client, review string) (models.Ranking, error) {
+	ranker, err := NewRanker(ctx, client)
+	if err != nil {
+		return models.Ranking{}, err
+	}
+	return ranker.Rank(ctx, review)
+}
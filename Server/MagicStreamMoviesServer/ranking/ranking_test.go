@@ -0,0 +1,35 @@
+package ranking
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+func TestCheck(t *testing.T) {
+	known := map[int]models.RankingDefinition{
+		1: {RankingValue: 1, RankingName: "Excellent"},
+		2: {RankingValue: 2, RankingName: "Good"},
+	}
+
+	tests := []struct {
+		ranking models.Ranking
+		ok      bool
+	}{
+		{models.Ranking{RankingValue: 1, RankingName: "Excellent"}, true},
+		{models.Ranking{RankingValue: 2, RankingName: "Good"}, true},
+		{models.Ranking{RankingValue: 1, RankingName: "Good"}, false},
+		{models.Ranking{RankingValue: 1, RankingName: "excellent"}, false},
+		{models.Ranking{RankingValue: 7, RankingName: "Excellent"}, false},
+	}
+	for _, tt := range tests {
+		err := Check(tt.ranking, known)
+		if tt.ok && err != nil {
+			t.Errorf("Check(%+v) = %v, want nil", tt.ranking, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrUnknownRanking) {
+			t.Errorf("Check(%+v) = %v, want ErrUnknownRanking", tt.ranking, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package recommend
+
+import (
+	"context"
+	"sort"
+)
+
+// blendPoolFactor is how many more results than requested are taken from
+// each strategy before blending, so that titles ranked highly by only one of
+// them can still make the cut.
+const blendPoolFactor = 4
+
+// BlendRecommender mixes a content-based and a collaborative recommender.
+// Each strategy's scores are scaled to 0..1 by its best result, then combined
+// as (1-CollaborativeWeight)*content + CollaborativeWeight*collaborative.
+type BlendRecommender struct {
+	content             Recommender
+	collaborative       Recommender
+	collaborativeWeight float64
+}
+
+func NewBlendRecommender(content, collaborative Recommender, collaborativeWeight float64) *BlendRecommender {
+	return &BlendRecommender{content: content, collaborative: collaborative, collaborativeWeight: collaborativeWeight}
+}
+
+func (r *BlendRecommender) Name() string {
+	return "blend"
+}
+
+func (r *BlendRecommender) Recommend(ctx context.Context, profile Profile, limit int) ([]Recommendation, error) {
+	content, err := r.content.Recommend(ctx, profile, limit*blendPoolFactor)
+	if err != nil {
+		return nil, err
+	}
+	collaborative, err := r.collaborative.Recommend(ctx, profile, limit*blendPoolFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	blended := map[string]*Recommendation{}
+	var order []string
+	merge := func(list []Recommendation, weight float64) {
+		best := 0.0
+		for _, rec := range list {
+			best = max(best, rec.Score)
+		}
+		for _, rec := range list {
+			share := 0.0
+			if best > 0 {
+				share = weight * rec.Score / best
+			}
+
+			b, ok := blended[rec.Movie.ImdbID]
+			if !ok {
+				b = &Recommendation{Movie: rec.Movie}
+				blended[rec.Movie.ImdbID] = b
+				order = append(order, rec.Movie.ImdbID)
+			}
+			b.Score += share
+			for _, reason := range rec.Reasons {
+				if best > 0 {
+					reason.Contribution = weight * reason.Contribution / best
+				}
+				b.Reasons = append(b.Reasons, reason)
+			}
+		}
+	}
+	merge(content, 1-r.collaborativeWeight)
+	merge(collaborative, r.collaborativeWeight)
+
+	recommendations := make([]Recommendation, 0, len(order))
+	for _, id := range order {
+		recommendations = append(recommendations, *blended[id])
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations, nil
+}
@@ -0,0 +1,127 @@
+package recommend
+
+import (
+	"context"
+	"sort"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/similarity"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// CollaborativeRecommender suggests the precomputed neighbours of the movies
+// the user watched or rated well: "users like you also watched". A candidate
+// scores the sum of its similarity to each seen movie, weighted by how much
+// the user engaged with that movie.
+type CollaborativeRecommender struct {
+	client *mongo.Client
+}
+
+func NewCollaborativeRecommender(client *mongo.Client) *CollaborativeRecommender {
+	return &CollaborativeRecommender{client: client}
+}
+
+func (r *CollaborativeRecommender) Name() string {
+	return "collaborative"
+}
+
+func (r *CollaborativeRecommender) Recommend(ctx context.Context, profile Profile, limit int) ([]Recommendation, error) {
+	seen := profile.excluded()
+	if len(seen) == 0 {
+		return []Recommendation{}, nil
+	}
+
+	neighbours, err := similarity.Neighbours(ctx, r.client, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	watched := map[string]bool{}
+	for _, id := range profile.Watched {
+		watched[id] = true
+	}
+	skip := map[string]bool{}
+	for _, id := range seen {
+		skip[id] = true
+	}
+
+	scores := map[string]float64{}
+	because := map[string]string{}
+	strongest := map[string]float64{}
+	for source, list := range neighbours {
+		weight := similarity.InteractionWeight(watched[source], profile.Ratings[source])
+		if weight == 0 {
+			continue
+		}
+		for _, n := range list {
+			if skip[n.ImdbID] {
+				continue
+			}
+			contribution := weight * n.Score
+			scores[n.ImdbID] += contribution
+			if contribution > strongest[n.ImdbID] {
+				strongest[n.ImdbID] = contribution
+				because[n.ImdbID] = source
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return []Recommendation{}, nil
+	}
+
+	movies, err := r.loadMovies(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := make([]Recommendation, 0, len(movies))
+	for _, m := range movies {
+		recommendations = append(recommendations, Recommendation{
+			Movie: m,
+			Score: scores[m.ImdbID],
+			Reasons: []Reason{{
+				Signal:       "collaborative",
+				Contribution: scores[m.ImdbID],
+				Detail:       "Users who watched " + because[m.ImdbID] + " also watched this",
+			}},
+		})
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Movie.ImdbID < recommendations[j].Movie.ImdbID
+	})
+
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations, nil
+}
+
+func (r *CollaborativeRecommender) loadMovies(ctx context.Context, ids []string) ([]models.Movie, error) {
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", r.client)
+
+	cursor, err := movieCollection.Find(ctx, bson.M{
+		"imdb_id":    bson.M{"$in": ids},
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var movies []models.Movie
+	if err := cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
@@ -0,0 +1,61 @@
+package recommend
+
+import (
+	"context"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// GenreRecommender is the original strategy: the best ranked movies in any of
+// the user's favorite genres. It is kept as a baseline for experiments.
+type GenreRecommender struct {
+	client *mongo.Client
+}
+
+func NewGenreRecommender(client *mongo.Client) *GenreRecommender {
+	return &GenreRecommender{client: client}
+}
+
+func (r *GenreRecommender) Name() string {
+	return "genre"
+}
+
+func (r *GenreRecommender) Recommend(ctx context.Context, profile Profile, limit int) ([]Recommendation, error) {
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", r.client)
+
+	filter := bson.M{
+		"genre.genre_name": bson.M{"$in": profile.FavoriteGenres},
+		"imdb_id":          bson.M{"$nin": profile.excluded()},
+		"deleted_at":       bson.M{"$exists": false},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "ranking.ranking_value", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := movieCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var movies []models.Movie
+	if err := cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+
+	recommendations := make([]Recommendation, 0, len(movies))
+	for _, m := range movies {
+		recommendations = append(recommendations, Recommendation{
+			Movie: m,
+			Reasons: []Reason{{
+				Signal: "genre",
+				Detail: "In one of your favorite genres, ranked " + m.Ranking.RankingName,
+			}},
+		})
+	}
+	return recommendations, nil
+}
@@ -0,0 +1,124 @@
+// Package recommend picks movies for a user. Strategies implement Recommender
+// so that they can be swapped or compared against each other through the
+// RECOMMENDER and RECOMMENDER_EXPERIMENT settings.
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Profile is what a recommender knows about the user it recommends for.
+type Profile struct {
+	UserID         string
+	FavoriteGenres []string
+	// Watched lists the imdb_ids in the user's watch history.
+	Watched []string
+	// Ratings maps imdb_id to the stars the user gave that movie.
+	Ratings map[string]int
+	// FavoriteDirectors lists the directors the user picked, as normalized
+	// names compared case-insensitively with a movie's directors.
+	FavoriteDirectors []string
+	// FavoriteActors lists the actors the user picked, compared the same way
+	// with a movie's cast.
+	FavoriteActors []string
+}
+
+// Reason explains how much one signal contributed to a recommendation.
+type Reason struct {
+	Signal       string  `json:"signal"`
+	Contribution float64 `json:"contribution"`
+	Detail       string  `json:"detail"`
+}
+
+type Recommendation struct {
+	Movie   models.Movie `json:"movie"`
+	Score   float64      `json:"score"`
+	Reasons []Reason     `json:"reasons,omitempty"`
+}
+
+// Recommender returns up to limit movies for the profile, best first. Movies
+// the user has already watched or rated are never recommended.
+type Recommender interface {
+	Name() string
+	Recommend(ctx context.Context, profile Profile, limit int) ([]Recommendation, error)
+}
+
+// defaultCollaborativeWeight is the share of the blend strategy's score that
+// comes from collaborative filtering unless RECOMMENDER_BLEND_WEIGHT says
+// otherwise.
+const defaultCollaborativeWeight = 0.4
+
+// New builds the recommender registered under name.
+func New(name string, client *mongo.Client) (Recommender, error) {
+	switch strings.ToLower(name) {
+	case "", "scoring":
+		return NewScoringRecommender(client, DefaultWeights), nil
+	case "genre":
+		return NewGenreRecommender(client), nil
+	case "collaborative":
+		return NewCollaborativeRecommender(client), nil
+	case "blend":
+		weight := defaultCollaborativeWeight
+		if v := os.Getenv("RECOMMENDER_BLEND_WEIGHT"); v != "" {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				return nil, fmt.Errorf("RECOMMENDER_BLEND_WEIGHT must be between 0 and 1, got %q", v)
+			}
+			weight = parsed
+		}
+		return NewBlendRecommender(
+			NewScoringRecommender(client, DefaultWeights),
+			NewCollaborativeRecommender(client),
+			weight,
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown recommender %q", name)
+	}
+}
+
+// ForUser returns the recommender userID should get. RECOMMENDER selects the
+// strategy (scoring by default). When RECOMMENDER_EXPERIMENT lists several
+// comma separated strategies, users are split between them by a stable hash
+// of their id, so each user keeps seeing the same variant.
+func ForUser(userID string, client *mongo.Client) (Recommender, error) {
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Println("Warning: .env file not found")
+	}
+
+	name := os.Getenv("RECOMMENDER")
+
+	var variants []string
+	for _, v := range strings.Split(os.Getenv("RECOMMENDER_EXPERIMENT"), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			variants = append(variants, v)
+		}
+	}
+	if len(variants) > 0 {
+		h := fnv.New32a()
+		h.Write([]byte(userID))
+		name = variants[h.Sum32()%uint32(len(variants))]
+	}
+
+	return New(name, client)
+}
+
+// excluded returns the imdb_ids a recommender must skip for the profile.
+func (p Profile) excluded() []string {
+	seen := make([]string, 0, len(p.Watched)+len(p.Ratings))
+	seen = append(seen, p.Watched...)
+	for id := range p.Ratings {
+		seen = append(seen, id)
+	}
+	return seen
+}
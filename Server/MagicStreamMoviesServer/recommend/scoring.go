@@ -0,0 +1,295 @@
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/ranking"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// candidatePoolSize caps how many movies are scored per request.
+const candidatePoolSize = 500
+
+// Weights scales each signal of the ScoringRecommender. Every signal is
+// normalised to roughly 0..1 per matching genre or per movie before weighting.
+type Weights struct {
+	GenreOverlap float64
+	Ratings      float64
+	History      float64
+	Ranking      float64
+	Recency      float64
+	// People is added for every favorite director of a movie, and half of it
+	// for every favorite actor in its cast.
+	People float64
+	// RecencyHalfLife is the catalog age at which the recency signal halves.
+	RecencyHalfLife time.Duration
+}
+
+var DefaultWeights = Weights{
+	GenreOverlap:    1.0,
+	Ratings:         1.5,
+	History:         0.5,
+	Ranking:         1.0,
+	Recency:         0.3,
+	People:          1.0,
+	RecencyHalfLife: 180 * 24 * time.Hour,
+}
+
+// ScoringRecommender scores candidate movies on a weighted sum of signals:
+// how many favorite genres they share, how the user rated and how often they
+// watched other movies in the same genres, favorite directors and actors, the
+// movie's ranking, and how recently it was added to the catalog.
+type ScoringRecommender struct {
+	client  *mongo.Client
+	weights Weights
+}
+
+func NewScoringRecommender(client *mongo.Client, weights Weights) *ScoringRecommender {
+	return &ScoringRecommender{client: client, weights: weights}
+}
+
+func (r *ScoringRecommender) Name() string {
+	return "scoring"
+}
+
+// genreAffinity is the user's learned taste for each genre name.
+type genreAffinity struct {
+	ratings map[string]float64
+	history map[string]float64
+}
+
+func (r *ScoringRecommender) Recommend(ctx context.Context, profile Profile, limit int) ([]Recommendation, error) {
+	affinity, err := r.learnAffinity(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	rankings, err := ranking.LoadRankings(ctx, r.client)
+	if err != nil {
+		return nil, err
+	}
+	best, worst := rankingBounds(rankings)
+
+	candidates, err := r.candidates(ctx, profile, affinity)
+	if err != nil {
+		return nil, err
+	}
+
+	favorites := map[string]bool{}
+	for _, g := range profile.FavoriteGenres {
+		favorites[g] = true
+	}
+
+	now := time.Now()
+	recommendations := make([]Recommendation, 0, len(candidates))
+	for _, movie := range candidates {
+		var reasons []Reason
+		add := func(signal string, contribution float64, detail string) {
+			if contribution != 0 {
+				reasons = append(reasons, Reason{Signal: signal, Contribution: contribution, Detail: detail})
+			}
+		}
+
+		var matched []string
+		var fromRatings, fromHistory float64
+		for _, g := range movie.Genre {
+			if favorites[g.GenreName] {
+				matched = append(matched, g.GenreName)
+			}
+			fromRatings += affinity.ratings[g.GenreName]
+			fromHistory += affinity.history[g.GenreName]
+		}
+
+		add("genre_overlap", r.weights.GenreOverlap*float64(len(matched)),
+			"Matches your favorite genres: "+strings.Join(matched, ", "))
+		add("ratings", r.weights.Ratings*fromRatings,
+			"Similar to movies you rated")
+		add("history", r.weights.History*fromHistory,
+			"Similar to movies you watched")
+
+		directors := matchPeople(movie.Directors, profile.FavoriteDirectors)
+		add("directors", r.weights.People*float64(len(directors)),
+			"Directed by "+strings.Join(directors, ", "))
+		actors := matchPeople(movie.Cast, profile.FavoriteActors)
+		add("cast", r.weights.People/2*float64(len(actors)),
+			"Starring "+strings.Join(actors, ", "))
+
+		if v := movie.Ranking.RankingValue; worst > best && v >= best && v <= worst {
+			add("ranking", r.weights.Ranking*float64(worst-v)/float64(worst-best),
+				"Ranked "+movie.Ranking.RankingName)
+		}
+
+		if r.weights.RecencyHalfLife > 0 && !movie.ID.IsZero() {
+			age := now.Sub(movie.ID.Timestamp())
+			decay := math.Exp2(-float64(age) / float64(r.weights.RecencyHalfLife))
+			add("recency", r.weights.Recency*decay,
+				fmt.Sprintf("Added to the catalog %d days ago", int(age.Hours()/24)))
+		}
+
+		score := 0.0
+		for _, reason := range reasons {
+			score += reason.Contribution
+		}
+		recommendations = append(recommendations, Recommendation{Movie: movie, Score: score, Reasons: reasons})
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Movie.ImdbID < recommendations[j].Movie.ImdbID
+	})
+
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations, nil
+}
+
+// learnAffinity derives genre preferences from the movies the user rated and
+// watched. A rating of 3 stars is neutral; higher ratings add up to +1 to each
+// genre of the movie and lower ratings subtract up to 1. Every watched movie
+// adds a fixed amount to its genres. Both are averaged per genre so that one
+// heavily watched genre does not drown out the rest.
+func (r *ScoringRecommender) learnAffinity(ctx context.Context, profile Profile) (genreAffinity, error) {
+	affinity := genreAffinity{ratings: map[string]float64{}, history: map[string]float64{}}
+
+	seen := profile.excluded()
+	if len(seen) == 0 {
+		return affinity, nil
+	}
+
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", r.client)
+
+	cursor, err := movieCollection.Find(ctx,
+		bson.M{"imdb_id": bson.M{"$in": seen}},
+		options.Find().SetProjection(bson.M{"imdb_id": 1, "genre": 1}),
+	)
+	if err != nil {
+		return affinity, err
+	}
+
+	var movies []models.Movie
+	if err := cursor.All(ctx, &movies); err != nil {
+		return affinity, err
+	}
+
+	watched := map[string]bool{}
+	for _, id := range profile.Watched {
+		watched[id] = true
+	}
+
+	ratedGenres, watchedGenres := map[string]int{}, map[string]int{}
+	for _, m := range movies {
+		stars, rated := profile.Ratings[m.ImdbID]
+		for _, g := range m.Genre {
+			if rated {
+				affinity.ratings[g.GenreName] += float64(stars-3) / 2
+				ratedGenres[g.GenreName]++
+			}
+			if watched[m.ImdbID] {
+				affinity.history[g.GenreName]++
+				watchedGenres[g.GenreName]++
+			}
+		}
+	}
+
+	for g, n := range ratedGenres {
+		affinity.ratings[g] /= float64(n)
+	}
+	total := len(profile.Watched)
+	for g := range watchedGenres {
+		affinity.history[g] /= float64(total)
+	}
+
+	return affinity, nil
+}
+
+// candidates loads the movies worth scoring: unseen movies in any genre the
+// user likes or with any of their favorite people, or the whole catalog when
+// nothing is known about the user yet.
+func (r *ScoringRecommender) candidates(ctx context.Context, profile Profile, affinity genreAffinity) ([]models.Movie, error) {
+	genres := append([]string{}, profile.FavoriteGenres...)
+	for g, a := range affinity.ratings {
+		if a > 0 {
+			genres = append(genres, g)
+		}
+	}
+	for g := range affinity.history {
+		genres = append(genres, g)
+	}
+
+	filter := bson.M{
+		"imdb_id":    bson.M{"$nin": profile.excluded()},
+		"deleted_at": bson.M{"$exists": false},
+	}
+
+	var likes bson.A
+	if len(genres) > 0 {
+		likes = append(likes, bson.M{"genre.genre_name": bson.M{"$in": genres}})
+	}
+	if len(profile.FavoriteDirectors) > 0 {
+		likes = append(likes, bson.M{"directors": bson.M{"$in": profile.FavoriteDirectors}})
+	}
+	if len(profile.FavoriteActors) > 0 {
+		likes = append(likes, bson.M{"cast": bson.M{"$in": profile.FavoriteActors}})
+	}
+	if len(likes) > 0 {
+		filter["$or"] = likes
+	}
+
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", r.client)
+
+	// Person names are matched case-insensitively, like GetMoviesByPerson.
+	opts := options.Find().
+		SetCollation(&options.Collation{Locale: "en", Strength: 2}).
+		SetSort(bson.D{{Key: "ranking.ranking_value", Value: 1}}).
+		SetLimit(candidatePoolSize)
+
+	cursor, err := movieCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var movies []models.Movie
+	if err := cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
+
+// matchPeople returns the names in people that are also in favorites,
+// ignoring case.
+func matchPeople(people []string, favorites []string) []string {
+	var matched []string
+	for _, p := range people {
+		for _, f := range favorites {
+			if strings.EqualFold(p, f) {
+				matched = append(matched, p)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// rankingBounds returns the best (lowest) and worst ranking values that a
+// review can be given, ignoring placeholders such as Not_Ranked.
+func rankingBounds(rankings []models.RankingDefinition) (int, int) {
+	best, worst := math.MaxInt, math.MinInt
+	for _, r := range ranking.Selectable(rankings) {
+		best = min(best, r.RankingValue)
+		worst = max(worst, r.RankingValue)
+	}
+	return best, worst
+}
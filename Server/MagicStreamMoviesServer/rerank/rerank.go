@@ -0,0 +1,380 @@
+// Package rerank recomputes the ranking of every reviewed movie, for example
+// after BASE_PROMPT_TEMPLATE or the rankings collection changed.
+package rerank
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/ranking"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	defaultConcurrency = 4
+	maxConcurrency     = 32
+	// batchesPerWorker sets how many movies are read per checkpoint.
+	batchesPerWorker = 4
+	// heartbeatInterval is how often a live run refreshes heartbeat_at.
+	heartbeatInterval = 30 * time.Second
+	// staleAfter is how long a running run may go without a heartbeat before
+	// Resume treats the process that ran it as dead and takes it over.
+	staleAfter = 3 * heartbeatInterval
+)
+
+var (
+	ErrRunCompleted  = errors.New("run already completed")
+	ErrRunInProgress = errors.New("run is still in progress")
+)
+
+type Options struct {
+	DryRun      bool
+	Concurrency int
+}
+
+// Start creates a new run record. Call Run to process it.
+func Start(ctx context.Context, client *mongo.Client, opts Options) (*models.RerankRun, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = defaultConcurrency
+	}
+	concurrency = min(concurrency, maxConcurrency)
+
+	now := time.Now()
+	run := &models.RerankRun{
+		Status:      models.RerankStatusRunning,
+		DryRun:      opts.DryRun,
+		Concurrency: concurrency,
+		StartedAt:   now,
+		UpdatedAt:   now,
+		HeartbeatAt: now,
+	}
+
+	var runCollection *mongo.Collection = database.OpenCollection("rerank_runs", client)
+
+	result, err := runCollection.InsertOne(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	run.ID = result.InsertedID.(bson.ObjectID)
+
+	return run, nil
+}
+
+// Get loads a run record by its hex ID.
+func Get(ctx context.Context, client *mongo.Client, id string) (*models.RerankRun, error) {
+	runID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid run id")
+	}
+
+	var runCollection *mongo.Collection = database.OpenCollection("rerank_runs", client)
+
+	var run models.RerankRun
+	if err := runCollection.FindOne(ctx, bson.M{"_id": runID}).Decode(&run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// Changes lists one page of the run's changes in imdb_id order, together with
+// the total number of changes.
+func Changes(ctx context.Context, client *mongo.Client, id string, page int64, pageSize int64) ([]models.RerankChange, int64, error) {
+	runID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, 0, errors.New("invalid run id")
+	}
+
+	var changeCollection *mongo.Collection = database.OpenCollection("rerank_changes", client)
+
+	filter := bson.M{"run_id": runID}
+	total, err := changeCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "imdb_id", Value: 1}}).
+		SetSkip((page - 1) * pageSize).
+		SetLimit(pageSize)
+
+	cursor, err := changeCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	changes := []models.RerankChange{}
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, 0, err
+	}
+	return changes, total, nil
+}
+
+// Resume claims an unfinished run and marks it as running again so Run
+// continues after the last checkpoint. A run still marked running can only be
+// claimed once its heartbeat is stale, i.e. the process running it died;
+// otherwise ErrRunInProgress is returned.
+func Resume(ctx context.Context, client *mongo.Client, id string) (*models.RerankRun, error) {
+	runID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid run id")
+	}
+
+	var runCollection *mongo.Collection = database.OpenCollection("rerank_runs", client)
+
+	now := time.Now()
+	filter := bson.M{
+		"_id": runID,
+		"$or": bson.A{
+			bson.M{"status": bson.M{"$nin": bson.A{models.RerankStatusRunning, models.RerankStatusCompleted}}},
+			bson.M{
+				"status":       models.RerankStatusRunning,
+				"heartbeat_at": bson.M{"$not": bson.M{"$gte": now.Add(-staleAfter)}},
+			},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"status":       models.RerankStatusRunning,
+		"updated_at":   now,
+		"heartbeat_at": now,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var run models.RerankRun
+	err = runCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&run)
+	if err == nil {
+		return &run, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	// Nothing was claimed: tell a missing run from one that cannot resume.
+	existing, err := Get(ctx, client, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing.Status == models.RerankStatusCompleted {
+		return nil, ErrRunCompleted
+	}
+	return nil, ErrRunInProgress
+}
+
+// Run walks every reviewed movie in imdb_id order after run.LastImdbID,
+// classifying run.Concurrency reviews at a time. Progress is saved after each
+// batch, so cancelling ctx loses at most one batch of work. onChange, if not
+// nil, is called for every movie whose ranking changed or failed to compute.
+func Run(ctx context.Context, client *mongo.Client, run *models.RerankRun, onChange func(models.RerankChange)) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go heartbeat(client, run.ID, stop)
+
+	ranker, err := ranking.NewRanker(ctx, client)
+	if err != nil {
+		return finish(client, run, err)
+	}
+
+	var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+	batchSize := int64(run.Concurrency * batchesPerWorker)
+
+	for {
+		filter := bson.M{
+			"imdb_id":      bson.M{"$gt": run.LastImdbID},
+			"admin_review": bson.M{"$nin": bson.A{"", nil}},
+			"deleted_at":   bson.M{"$exists": false},
+		}
+		opts := options.Find().
+			SetSort(bson.D{{Key: "imdb_id", Value: 1}}).
+			SetLimit(batchSize).
+			SetProjection(bson.M{"imdb_id": 1, "title": 1, "admin_review": 1, "ranking": 1})
+
+		cursor, err := movieCollection.Find(ctx, filter, opts)
+		if err != nil {
+			return finish(client, run, err)
+		}
+
+		var movies []models.Movie
+		if err := cursor.All(ctx, &movies); err != nil {
+			return finish(client, run, err)
+		}
+		if len(movies) == 0 {
+			return finish(client, run, nil)
+		}
+
+		changes, failed := rankBatch(ctx, client, ranker, movies, run.Concurrency, run.DryRun)
+		if ctx.Err() != nil {
+			// The batch may be incomplete; leave the checkpoint where it was.
+			return finish(client, run, ctx.Err())
+		}
+
+		for _, change := range changes {
+			if onChange != nil {
+				onChange(change)
+			}
+		}
+
+		run.LastImdbID = movies[len(movies)-1].ImdbID
+		run.Processed += len(movies)
+		run.Changed += len(changes) - failed
+		run.Failed += failed
+
+		if err := checkpoint(client, run, changes, len(movies), failed); err != nil {
+			return finish(client, run, err)
+		}
+	}
+}
+
+func rankBatch(ctx context.Context, client *mongo.Client, ranker *ranking.Ranker, movies []models.Movie, concurrency int, dryRun bool) ([]models.RerankChange, int) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		changes []models.RerankChange
+		failed  int
+	)
+
+	sem := make(chan struct{}, concurrency)
+	for _, movie := range movies {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(movie models.Movie) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			change := models.RerankChange{ImdbID: movie.ImdbID, Title: movie.Title, Old: movie.Ranking}
+
+			result, err := ranker.Rank(ctx, movie.AdminReview)
+			if err == nil && result != movie.Ranking && !dryRun {
+				err = database.WithTransaction(ctx, client, func(ctx context.Context) error {
+					if err := ranking.Resolve(ctx, client, result); err != nil {
+						return err
+					}
+
+					var movieCollection *mongo.Collection = database.OpenCollection("movies", client)
+
+					_, err := movieCollection.UpdateOne(ctx,
+						bson.M{"imdb_id": movie.ImdbID, "admin_review": movie.AdminReview},
+						bson.M{"$set": bson.M{
+							"ranking":        result,
+							"ranking_status": models.RankingStatusRanked,
+						}},
+					)
+					return err
+				})
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				change.Error = err.Error()
+				changes = append(changes, change)
+				failed++
+			case result != movie.Ranking:
+				change.New = result
+				changes = append(changes, change)
+			}
+		}(movie)
+	}
+	wg.Wait()
+
+	return changes, failed
+}
+
+// heartbeat refreshes the run's heartbeat_at until stop is closed, so that
+// Resume can tell a live run from one whose process died.
+func heartbeat(client *mongo.Client, runID bson.ObjectID, stop <-chan struct{}) {
+	var runCollection *mongo.Collection = database.OpenCollection("rerank_runs", client)
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), heartbeatInterval)
+			_, err := runCollection.UpdateOne(ctx,
+				bson.M{"_id": runID, "status": models.RerankStatusRunning},
+				bson.M{"$set": bson.M{"heartbeat_at": time.Now()}},
+			)
+			cancel()
+			if err != nil {
+				log.Printf("rerank run %s: saving heartbeat: %v", runID.Hex(), err)
+			}
+		}
+	}
+}
+
+// checkpoint stores the batch's changes and advances the run's counters and
+// last_imdb_id together, so a resumed run neither repeats nor loses changes.
+func checkpoint(client *mongo.Client, run *models.RerankRun, changes []models.RerankChange, processed int, failed int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var runCollection *mongo.Collection = database.OpenCollection("rerank_runs", client)
+	var changeCollection *mongo.Collection = database.OpenCollection("rerank_changes", client)
+
+	return database.WithTransaction(ctx, client, func(ctx context.Context) error {
+		if len(changes) > 0 {
+			docs := make([]models.RerankChange, len(changes))
+			for i, change := range changes {
+				change.RunID = run.ID
+				docs[i] = change
+			}
+			if _, err := changeCollection.InsertMany(ctx, docs); err != nil {
+				return err
+			}
+		}
+
+		_, err := runCollection.UpdateOne(ctx, bson.M{"_id": run.ID}, bson.M{
+			"$set": bson.M{"last_imdb_id": run.LastImdbID, "updated_at": time.Now()},
+			"$inc": bson.M{"processed": processed, "changed": len(changes) - failed, "failed": failed},
+		})
+		return err
+	})
+}
+
+// finish stores the final status of the run and returns runErr unchanged.
+func finish(client *mongo.Client, run *models.RerankRun, runErr error) error {
+	// Use a fresh context: the run's own context may be the reason we stopped.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	run.UpdatedAt = now
+	set := bson.M{"updated_at": now}
+
+	switch {
+	case runErr == nil:
+		run.Status = models.RerankStatusCompleted
+		run.FinishedAt = &now
+		set["finished_at"] = now
+	case errors.Is(runErr, context.Canceled) || errors.Is(runErr, context.DeadlineExceeded):
+		run.Status = models.RerankStatusInterrupted
+	default:
+		run.Status = models.RerankStatusFailed
+		run.Error = runErr.Error()
+		set["error"] = run.Error
+	}
+	set["status"] = run.Status
+
+	var runCollection *mongo.Collection = database.OpenCollection("rerank_runs", client)
+
+	if _, err := runCollection.UpdateOne(ctx, bson.M{"_id": run.ID}, bson.M{"$set": set}); err != nil {
+		if runErr == nil {
+			return err
+		}
+		return fmt.Errorf("%w (saving run status: %v)", runErr, err)
+	}
+	return runErr
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/rerank"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// runRerankCommand implements `server rerank [-dry-run] [-concurrency n]
+// [-resume run_id]`. Interrupting it with Ctrl-C saves progress; pass the
+// printed run ID to -resume to continue.
+func runRerankCommand(args []string) {
+	flags := flag.NewFlagSet("rerank", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print ranking changes without saving them")
+	concurrency := flags.Int("concurrency", 4, "number of reviews to classify at once")
+	resume := flags.String("resume", "", "ID of an interrupted run to continue")
+	flags.Parse(args)
+
+	client := database.Connect()
+	if err := client.Ping(context.Background(), nil); err != nil {
+		log.Fatalf("Failed to reach server: %v", err)
+	}
+
+	// Exit only once the client is closed: log.Fatal inside the run would
+	// skip the disconnect.
+	err := rerankCatalog(client, *dryRun, *concurrency, *resume)
+	if disconnectErr := client.Disconnect(context.Background()); disconnectErr != nil {
+		log.Printf("Failed to disconnect from MongoDB: %v", disconnectErr)
+	}
+	if err != nil {
+		log.Printf("Rerank failed: %v", err)
+		os.Exit(1)
+	}
+}
+
+// rerankCatalog starts or resumes a run and prints each change as it is made.
+func rerankCatalog(client *mongo.Client, dryRun bool, concurrency int, resume string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var run *models.RerankRun
+	var err error
+	if resume != "" {
+		run, err = rerank.Resume(ctx, client, resume)
+	} else {
+		run, err = rerank.Start(ctx, client, rerank.Options{DryRun: dryRun, Concurrency: concurrency})
+	}
+	if err != nil {
+		return fmt.Errorf("starting run: %w", err)
+	}
+
+	mode := "applying changes"
+	if run.DryRun {
+		mode = "dry run"
+	}
+	fmt.Printf("Rerank run %s (%s), resuming after %q\n", run.ID.Hex(), mode, run.LastImdbID)
+
+	err = rerank.Run(ctx, client, run, func(change models.RerankChange) {
+		if change.Error != "" {
+			fmt.Printf("! %s  %s: %s\n", change.ImdbID, change.Title, change.Error)
+			return
+		}
+		fmt.Printf("~ %s  %s: %s (%d) -> %s (%d)\n", change.ImdbID, change.Title,
+			change.Old.RankingName, change.Old.RankingValue, change.New.RankingName, change.New.RankingValue)
+	})
+
+	fmt.Printf("%s: %d processed, %d changed, %d failed\n", run.Status, run.Processed, run.Changed, run.Failed)
+	if err != nil {
+		return fmt.Errorf("run %s stopped: %w (resume with -resume %s)", run.ID.Hex(), err, run.ID.Hex())
+	}
+	return nil
+}
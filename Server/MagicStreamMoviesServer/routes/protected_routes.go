@@ -1,17 +1,74 @@
 package routes
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
 	controller "github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/controllers"
 	middleware "github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/middleware"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
-func SetupProtectedRoutes(router *gin.Engine, client *mongo.Client) {
-	router.Use(middleware.AuthMiddleware())
+// SetupProtectedRoutes registers the routes that need a signed-in caller.
+// serverCtx is cancelled when the server shuts down and bounds the work
+// handlers start in the background.
+func SetupProtectedRoutes(serverCtx context.Context, router *gin.Engine, client *mongo.Client) {
+	router.Use(middleware.AuthMiddleware(client))
 
 	router.GET("/movie/:imdb_id", controller.GetMovie(client))
-	router.POST("/addmovie", controller.AddMovie(client))
 	router.GET("/recommendedmovies", controller.GetRecommendedMovies(client))
-	router.PATCH("/updatereview/:imdb_id", controller.AdminReviewUpdate(client))
+	router.POST("/movie/:imdb_id/watched", controller.MarkMovieWatched(client))
+	router.PUT("/movie/:imdb_id/rating", controller.RateMovie(client))
+	router.GET("/movie/:imdb_id/similar", controller.GetSimilarMovies(client))
+	router.GET("/movie/:imdb_id/reviews", controller.GetMovieReviews(client))
+	router.POST("/movie/:imdb_id/reviews", controller.CreateUserReview(client))
+	router.PATCH("/reviews/:review_id", controller.UpdateUserReview(client))
+	router.DELETE("/reviews/:review_id", controller.DeleteUserReview(client))
+	router.GET("/me", controller.GetProfile(client))
+	router.PATCH("/me", middleware.RequireSession(), controller.UpdateProfile(client))
+	router.DELETE("/me", middleware.RequireSession(), controller.DeleteAccount(client))
+	router.PUT("/me/password", middleware.RequireSession(), controller.ChangePassword(client))
+	router.GET("/me/sessions", controller.GetSessions(client))
+	router.DELETE("/me/sessions/:session_id", controller.RevokeSession(client))
+	router.GET("/me/api-keys", controller.GetAPIKeys(client))
+	router.POST("/me/api-keys", middleware.RequireSession(), controller.CreateAPIKey(client))
+	router.DELETE("/me/api-keys/:key_id", controller.RevokeAPIKey(client))
+	router.PUT("/me/favorite-genres", controller.UpdateFavoriteGenres(client))
+	router.PUT("/me/favorite-people", controller.UpdateFavoritePeople(client))
+	router.GET("/me/watchlist", controller.GetWatchlist(client))
+	router.PUT("/me/watchlist", controller.ReorderWatchlist(client))
+	router.POST("/me/watchlist/:imdb_id", controller.AddToWatchlist(client))
+	router.DELETE("/me/watchlist/:imdb_id", controller.RemoveFromWatchlist(client))
+	router.GET("/me/history", controller.GetWatchHistory(client))
+	router.DELETE("/me/history", controller.ClearWatchHistory(client))
+	router.DELETE("/me/history/:imdb_id", controller.DeleteWatchHistoryEntry(client))
+
+	movieWriters := router.Group("", middleware.RequirePermission(middleware.PermissionMoviesWrite))
+	movieWriters.POST("/addmovie", controller.AddMovie(client))
+	movieWriters.PUT("/movie/:imdb_id", controller.UpdateMovie(client))
+	movieWriters.PATCH("/movie/:imdb_id", controller.PatchMovie(client))
+	movieWriters.DELETE("/movie/:imdb_id", controller.DeleteMovie(client))
+	movieWriters.POST("/movie/:imdb_id/restore", controller.RestoreMovie(client))
+	movieWriters.POST("/movies/bulk", controller.ImportMovies(client))
+	movieWriters.PATCH("/updatereview/:imdb_id", controller.AdminReviewUpdate(client))
+	movieWriters.GET("/movie/:imdb_id/ranking-status", controller.GetRankingStatus(client))
+
+	reviewModerators := router.Group("", middleware.RequirePermission(middleware.PermissionReviewsWrite))
+	reviewModerators.GET("/reviews/moderation", controller.GetModerationQueue(client))
+	reviewModerators.POST("/reviews/:review_id/approve", controller.ApproveUserReview(client))
+	reviewModerators.POST("/reviews/:review_id/hide", controller.HideUserReview(client))
+
+	admin := router.Group("", middleware.RequireRole("ADMIN"))
+	admin.POST("/genres", controller.CreateGenre(client))
+	admin.PUT("/genres/:genre_id", controller.RenameGenre(client))
+	admin.POST("/genres/:genre_id/merge", controller.MergeGenre(client))
+	admin.DELETE("/genres/:genre_id", controller.DeleteGenre(client))
+	admin.POST("/rankings", controller.CreateRanking(client))
+	admin.PUT("/rankings/:ranking_value", controller.UpdateRanking(client))
+	admin.DELETE("/rankings/:ranking_value", controller.DeleteRanking(client))
+	admin.POST("/rerank", controller.StartRerank(serverCtx, client))
+	admin.GET("/rerank/:run_id", controller.GetRerankRun(client))
+	admin.GET("/rerank/:run_id/changes", controller.GetRerankRunChanges(client))
+	admin.POST("/users/:user_id/unlock", controller.UnlockUser(client))
+	admin.GET("/login-audit", controller.GetLoginAudit(client))
 }
@@ -3,14 +3,23 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	controller "github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/controllers"
+	middleware "github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/middleware"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 func SetupUnprotectedRoutes(router *gin.Engine, client *mongo.Client) {
-	router.GET("/movies", controller.GetMovies(client))
+	router.GET("/movies", middleware.OptionalAuthMiddleware(client), controller.GetMovies(client))
+	router.GET("/movies/search", controller.SearchMovies(client))
+	router.GET("/people/:name/movies", middleware.OptionalAuthMiddleware(client), controller.GetMoviesByPerson(client))
 	router.POST("/register", controller.RegisterUser(client))
 	router.POST("/login", controller.LoginUser(client))
 	router.POST("/logout", controller.LogoutHandler(client))
+	router.POST("/verify-email", controller.VerifyEmail(client))
+	router.POST("/verify-email/resend", controller.ResendVerificationEmail(client))
+	router.POST("/password/forgot", controller.ForgotPassword(client))
+	router.POST("/password/reset", controller.ResetPassword(client))
 	router.GET("/genres", controller.GetGenres(client))
+	router.GET("/rankings", controller.GetRankings(client))
 	router.POST("/refresh", controller.RefreshTokenHandler(client))
+	router.GET("/.well-known/jwks.json", controller.GetJWKS())
 }
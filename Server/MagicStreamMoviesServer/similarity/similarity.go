@@ -0,0 +1,325 @@
+// Package similarity computes item-to-item collaborative filtering
+// neighbours from ratings and watch history. Two movies are similar when the
+// same users engaged with both; the score is the cosine similarity of their
+// user interaction vectors. Results are stored in movie_similarities, one
+// document per movie.
+package similarity
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	defaultNeighbours = 20
+	defaultMinCoUsers = 2
+	// maxItemsPerUser bounds the pairs generated by one user, whose count
+	// grows with the square of their history.
+	maxItemsPerUser = 300
+	writeBatchSize  = 500
+)
+
+type Options struct {
+	// Neighbours is how many similar movies are kept per movie.
+	Neighbours int
+	// MinCoUsers drops pairs shared by fewer users, which are mostly noise.
+	MinCoUsers int
+}
+
+type Stats struct {
+	Users  int
+	Movies int
+	Pairs  int
+}
+
+// interaction is a user's engagement with one movie: its weight and when the
+// user last watched or rated it.
+type interaction struct {
+	Weight float64
+	At     time.Time
+}
+
+// InteractionWeight turns a user's engagement with a movie into a weight in
+// 0..1. Watching counts fully; a rating counts in proportion to its stars,
+// with one and two stars treated as no interest at all. The stronger of the
+// two signals wins.
+func InteractionWeight(watched bool, stars int) float64 {
+	weight := 0.0
+	if watched {
+		weight = 1
+	}
+	if stars > 2 {
+		weight = max(weight, float64(stars-2)/3)
+	}
+	return weight
+}
+
+// Compute rebuilds movie_similarities from the current ratings and watch
+// history. Movies that no longer have any neighbours lose their document.
+func Compute(ctx context.Context, client *mongo.Client, opts Options) (Stats, error) {
+	if opts.Neighbours < 1 {
+		opts.Neighbours = defaultNeighbours
+	}
+	if opts.MinCoUsers < 1 {
+		opts.MinCoUsers = defaultMinCoUsers
+	}
+
+	started := time.Now()
+
+	interactions, err := loadInteractions(ctx, client)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	norms := map[string]float64{}
+	dots := map[string]map[string]float64{}
+	counts := map[string]map[string]int{}
+
+	for _, items := range interactions {
+		ids := topItems(items, maxItemsPerUser)
+
+		for i, a := range ids {
+			wa := items[a].Weight
+			norms[a] += wa * wa
+			for _, b := range ids[i+1:] {
+				if dots[a] == nil {
+					dots[a], counts[a] = map[string]float64{}, map[string]int{}
+				}
+				dots[a][b] += wa * items[b].Weight
+				counts[a][b]++
+			}
+		}
+	}
+
+	neighbours := map[string][]models.Neighbour{}
+	stats := Stats{Users: len(interactions)}
+	for a, row := range dots {
+		for b, dot := range row {
+			n := counts[a][b]
+			if n < opts.MinCoUsers {
+				continue
+			}
+			score := dot / math.Sqrt(norms[a]*norms[b])
+			neighbours[a] = append(neighbours[a], models.Neighbour{ImdbID: b, Score: score, CoUsers: n})
+			neighbours[b] = append(neighbours[b], models.Neighbour{ImdbID: a, Score: score, CoUsers: n})
+			stats.Pairs++
+		}
+	}
+	stats.Movies = len(neighbours)
+
+	if err := store(ctx, client, neighbours, opts.Neighbours, started); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// topItems returns the ids of the user's movies with a positive weight, in
+// sorted order so that every user keys a pair the same way. Past limit only
+// the strongest interactions are kept, the most recent first among equals.
+func topItems(items map[string]interaction, limit int) []string {
+	ids := make([]string, 0, len(items))
+	for id, it := range items {
+		if it.Weight > 0 {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > limit {
+		sort.Slice(ids, func(i, j int) bool {
+			a, b := items[ids[i]], items[ids[j]]
+			if a.Weight != b.Weight {
+				return a.Weight > b.Weight
+			}
+			if !a.At.Equal(b.At) {
+				return a.At.After(b.At)
+			}
+			return ids[i] < ids[j]
+		})
+		ids = ids[:limit]
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// loadInteractions returns, per user, every movie they engaged with.
+func loadInteractions(ctx context.Context, client *mongo.Client) (map[string]map[string]interaction, error) {
+	type entry struct {
+		UserID    string    `bson:"user_id"`
+		ImdbID    string    `bson:"imdb_id"`
+		Stars     int       `bson:"stars"`
+		WatchedAt time.Time `bson:"watched_at"`
+		UpdatedAt time.Time `bson:"updated_at"`
+	}
+
+	watched := map[string]map[string]bool{}
+	stars := map[string]map[string]int{}
+	latest := map[string]map[string]time.Time{}
+
+	projection := options.Find().SetProjection(bson.M{
+		"user_id": 1, "imdb_id": 1, "stars": 1, "watched_at": 1, "updated_at": 1, "_id": 0,
+	})
+
+	for _, name := range []string{"watch_history", "ratings"} {
+		collection := database.OpenCollection(name, client)
+		cursor, err := collection.Find(ctx, bson.M{}, projection)
+		if err != nil {
+			return nil, err
+		}
+
+		for cursor.Next(ctx) {
+			var e entry
+			if err := cursor.Decode(&e); err != nil {
+				cursor.Close(ctx)
+				return nil, err
+			}
+			at := e.UpdatedAt
+			if name == "watch_history" {
+				if watched[e.UserID] == nil {
+					watched[e.UserID] = map[string]bool{}
+				}
+				watched[e.UserID][e.ImdbID] = true
+				at = e.WatchedAt
+			} else {
+				if stars[e.UserID] == nil {
+					stars[e.UserID] = map[string]int{}
+				}
+				stars[e.UserID][e.ImdbID] = e.Stars
+			}
+			if latest[e.UserID] == nil {
+				latest[e.UserID] = map[string]time.Time{}
+			}
+			if at.After(latest[e.UserID][e.ImdbID]) {
+				latest[e.UserID][e.ImdbID] = at
+			}
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	interactions := map[string]map[string]interaction{}
+	add := func(userID, imdbID string) {
+		if interactions[userID] == nil {
+			interactions[userID] = map[string]interaction{}
+		}
+		interactions[userID][imdbID] = interaction{
+			Weight: InteractionWeight(watched[userID][imdbID], stars[userID][imdbID]),
+			At:     latest[userID][imdbID],
+		}
+	}
+	for userID, movies := range watched {
+		for imdbID := range movies {
+			add(userID, imdbID)
+		}
+	}
+	for userID, movies := range stars {
+		for imdbID := range movies {
+			add(userID, imdbID)
+		}
+	}
+	return interactions, nil
+}
+
+// store replaces each movie's neighbour document and removes the documents
+// this run did not produce.
+func store(ctx context.Context, client *mongo.Client, neighbours map[string][]models.Neighbour, limit int, started time.Time) error {
+	var similarityCollection *mongo.Collection = database.OpenCollection("movie_similarities", client)
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, writeBatchSize)
+	flush := func() error {
+		if len(writes) == 0 {
+			return nil
+		}
+		_, err := similarityCollection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		writes = writes[:0]
+		return err
+	}
+
+	for imdbID, list := range neighbours {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			return list[i].ImdbID < list[j].ImdbID
+		})
+		if len(list) > limit {
+			list = list[:limit]
+		}
+
+		doc := models.MovieSimilarity{ImdbID: imdbID, Neighbours: list, ComputedAt: now}
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"imdb_id": imdbID}).
+			SetReplacement(doc).
+			SetUpsert(true))
+
+		if len(writes) >= writeBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	_, err := similarityCollection.DeleteMany(ctx, bson.M{"computed_at": bson.M{"$lt": started}})
+	return err
+}
+
+// Neighbours returns the stored neighbours of each of the given movies.
+func Neighbours(ctx context.Context, client *mongo.Client, imdbIDs []string) (map[string][]models.Neighbour, error) {
+	var similarityCollection *mongo.Collection = database.OpenCollection("movie_similarities", client)
+
+	cursor, err := similarityCollection.Find(ctx, bson.M{"imdb_id": bson.M{"$in": imdbIDs}})
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []models.MovieSimilarity
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	neighbours := make(map[string][]models.Neighbour, len(docs))
+	for _, d := range docs {
+		neighbours[d.ImdbID] = d.Neighbours
+	}
+	return neighbours, nil
+}
+
+// StartScheduler recomputes similarities every interval until ctx is
+// cancelled. The first run starts immediately.
+func StartScheduler(ctx context.Context, client *mongo.Client, interval time.Duration, opts Options) {
+	go func() {
+		for {
+			runCtx, cancel := context.WithTimeout(ctx, interval)
+			stats, err := Compute(runCtx, client, opts)
+			cancel()
+			if err != nil {
+				log.Println("similarity: compute failed:", err)
+			} else {
+				log.Printf("similarity: %d movies from %d users (%d pairs)", stats.Movies, stats.Users, stats.Pairs)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	log.Printf("Recomputing movie similarities every %s", interval)
+}
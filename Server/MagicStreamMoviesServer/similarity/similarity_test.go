@@ -0,0 +1,53 @@
+package similarity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTopItemsKeepsTheStrongestAndMostRecent(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	items := map[string]interaction{
+		"tt0000001": {Weight: 1.0 / 3, At: now},
+		"tt0000002": {Weight: 1, At: now.Add(-3 * time.Hour)},
+		"tt0000003": {Weight: 0, At: now},
+		"tt9000001": {Weight: 1, At: now.Add(-time.Hour)},
+		"tt9000002": {Weight: 1, At: now.Add(-2 * time.Hour)},
+		"tt9000003": {Weight: 2.0 / 3, At: now},
+	}
+
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{10, []string{"tt0000001", "tt0000002", "tt9000001", "tt9000002", "tt9000003"}},
+		{4, []string{"tt0000002", "tt9000001", "tt9000002", "tt9000003"}},
+		{2, []string{"tt9000001", "tt9000002"}},
+	}
+	for _, tt := range tests {
+		if got := topItems(items, tt.limit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("topItems(limit %d) = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestInteractionWeight(t *testing.T) {
+	tests := []struct {
+		watched bool
+		stars   int
+		want    float64
+	}{
+		{false, 0, 0},
+		{false, 2, 0},
+		{false, 5, 1},
+		{false, 4, 2.0 / 3},
+		{true, 0, 1},
+		{true, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := InteractionWeight(tt.watched, tt.stars); got != tt.want {
+			t.Errorf("InteractionWeight(%v, %d) = %v, want %v", tt.watched, tt.stars, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/similarity"
+)
+
+// runSimilarityCommand implements `server similarity [-neighbours n]
+// [-min-co-users n]`, which rebuilds the collaborative filtering neighbours
+// used by /movie/:imdb_id/similar and the collaborative recommenders.
+func runSimilarityCommand(args []string) {
+	flags := flag.NewFlagSet("similarity", flag.ExitOnError)
+	neighbours := flags.Int("neighbours", 20, "number of similar movies to keep per movie")
+	minCoUsers := flags.Int("min-co-users", 2, "minimum number of shared users for two movies to be similar")
+	flags.Parse(args)
+
+	client := database.Connect()
+	if err := client.Ping(context.Background(), nil); err != nil {
+		log.Fatalf("Failed to reach server: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	stats, err := similarity.Compute(ctx, client, similarity.Options{Neighbours: *neighbours, MinCoUsers: *minCoUsers})
+	if err != nil {
+		log.Fatalf("Failed to compute similarities: %v", err)
+	}
+
+	fmt.Printf("Computed neighbours for %d movies from %d users (%d similar pairs)\n", stats.Movies, stats.Users, stats.Pairs)
+}
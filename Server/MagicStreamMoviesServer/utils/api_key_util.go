@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// APIKeyPrefix starts every API key, which is how a bearer credential is told
+// apart from a JWT.
+const APIKeyPrefix = "msk_"
+
+// apiKeyTouchInterval limits how often last_used_at is written for a key that
+// is used on every request.
+const apiKeyTouchInterval = time.Minute
+
+var ErrAPIKeyNotFound = errors.New("API key not found, revoked or expired")
+
+// IsAPIKey reports whether a bearer credential is an API key.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// GenerateAPIKey returns a new random key and the prefix shown in listings.
+func GenerateAPIKey() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:len(APIKeyPrefix)+8], nil
+}
+
+// FindAPIKey looks up an active key by its value and records that it was
+// used.
+func FindAPIKey(ctx context.Context, client *mongo.Client, key string) (models.APIKey, error) {
+	var apiKeyCollection *mongo.Collection = database.OpenCollection("api_keys", client)
+
+	now := time.Now()
+	var apiKey models.APIKey
+	err := apiKeyCollection.FindOne(ctx, bson.M{
+		"key_hash":   HashToken(key),
+		"revoked_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": now}},
+		},
+	}).Decode(&apiKey)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return apiKey, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return apiKey, err
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		_, err = apiKeyCollection.UpdateOne(ctx,
+			bson.M{"_id": apiKey.ID},
+			bson.M{"$set": bson.M{"last_used_at": now}},
+		)
+		if err != nil {
+			return apiKey, err
+		}
+	}
+
+	return apiKey, nil
+}
+
+// GetAPIKeyIdFromContext returns the API key the request was authenticated
+// with. It fails for requests authenticated with a session token.
+func GetAPIKeyIdFromContext(c *gin.Context) (string, error) {
+	keyId, exist := c.Get("apiKeyId")
+	if !exist {
+		return "", errors.New("apiKeyId does not exist in this context")
+	}
+
+	id, ok := keyId.(string)
+	if !ok {
+		return "", errors.New("unable to retrieve apiKeyId")
+	}
+
+	return id, nil
+}
+
+// AuthenticateAPIKey resolves an API key to its owner's current role, so that
+// demoting a user also narrows what their existing keys can do.
+func AuthenticateAPIKey(ctx context.Context, client *mongo.Client, key string) (models.APIKey, string, error) {
+	apiKey, err := FindAPIKey(ctx, client, key)
+	if err != nil {
+		return apiKey, "", err
+	}
+
+	var userCollection *mongo.Collection = database.OpenCollection("users", client)
+
+	var user models.User
+	err = userCollection.FindOne(ctx, bson.M{"user_id": apiKey.UserID}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return apiKey, "", ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return apiKey, "", err
+	}
+
+	return apiKey, user.Role, nil
+}
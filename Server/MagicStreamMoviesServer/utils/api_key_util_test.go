@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// testClient connects to the MongoDB named by MONGODB_TEST_URI and points
+// OpenCollection at a throwaway database.
+func testClient(t *testing.T) *mongo.Client {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	databaseName := fmt.Sprintf("magicstream_test_%d", time.Now().UnixNano())
+	t.Setenv("DATABASE_NAME", databaseName)
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_ = client.Database(databaseName).Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	return client
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsAPIKey(key) || !strings.HasPrefix(key, prefix) || len(prefix) != len(APIKeyPrefix)+8 {
+		t.Errorf("GenerateAPIKey = %q, %q", key, prefix)
+	}
+	if IsAPIKey("eyJhbGciOiJFZERTQSJ9.e30.sig") {
+		t.Error("a JWT was taken for an API key")
+	}
+
+	other, _, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == key {
+		t.Error("GenerateAPIKey returned the same key twice")
+	}
+
+	// Only the hash is stored, so it must be stable and not reveal the key.
+	if HashToken(key) != HashToken(key) || HashToken(key) == HashToken(other) || strings.Contains(HashToken(key), key[len(APIKeyPrefix):]) {
+		t.Error("HashToken does not identify the key")
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	client := testClient(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	userCollection := database.OpenCollection("users", client)
+	apiKeyCollection := database.OpenCollection("api_keys", client)
+
+	if _, err := userCollection.InsertOne(ctx, bson.M{"user_id": "u1", "role": "ADMIN"}); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string]string{}
+	insert := func(name string, userId string, mutate func(*models.APIKey)) {
+		key, prefix, err := GenerateAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		apiKey := models.APIKey{
+			ID:        bson.NewObjectID(),
+			UserID:    userId,
+			Name:      name,
+			Scope:     models.APIKeyScopeAdmin,
+			KeyHash:   HashToken(key),
+			Prefix:    prefix,
+			CreatedAt: now,
+		}
+		if mutate != nil {
+			mutate(&apiKey)
+		}
+		if _, err := apiKeyCollection.InsertOne(ctx, apiKey); err != nil {
+			t.Fatal(err)
+		}
+		keys[name] = key
+	}
+
+	revokedAt, expiredAt, expiresAt := now.Add(-time.Minute), now.Add(-time.Second), now.Add(time.Hour)
+	insert("active", "u1", nil)
+	insert("expiring", "u1", func(k *models.APIKey) { k.ExpiresAt = &expiresAt })
+	insert("revoked", "u1", func(k *models.APIKey) { k.RevokedAt = &revokedAt })
+	insert("expired", "u1", func(k *models.APIKey) { k.ExpiresAt = &expiredAt })
+	insert("orphaned", "deleted-user", nil)
+
+	for _, name := range []string{"active", "expiring"} {
+		apiKey, role, err := AuthenticateAPIKey(ctx, client, keys[name])
+		if err != nil || apiKey.Name != name || role != "ADMIN" {
+			t.Errorf("%s: AuthenticateAPIKey = %q, %q, %v", name, apiKey.Name, role, err)
+		}
+	}
+
+	var touched models.APIKey
+	if err := apiKeyCollection.FindOne(ctx, bson.M{"name": "active"}).Decode(&touched); err != nil {
+		t.Fatal(err)
+	}
+	if touched.LastUsedAt == nil {
+		t.Error("last_used_at was not recorded")
+	}
+
+	// The role comes from the user, not from when the key was created.
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"user_id": "u1"}, bson.M{"$set": bson.M{"role": "USER"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, role, err := AuthenticateAPIKey(ctx, client, keys["active"]); err != nil || role != "USER" {
+		t.Errorf("after demotion: role %q, %v", role, err)
+	}
+
+	rejected := map[string]string{
+		"revoked":   keys["revoked"],
+		"expired":   keys["expired"],
+		"orphaned":  keys["orphaned"],
+		"unknown":   APIKeyPrefix + "unknown",
+		"malformed": "msk_",
+	}
+	for name, key := range rejected {
+		if _, _, err := AuthenticateAPIKey(ctx, client, key); !errors.Is(err, ErrAPIKeyNotFound) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrAPIKeyNotFound)
+		}
+	}
+}
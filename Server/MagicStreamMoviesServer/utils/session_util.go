@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrSessionNotFound        = errors.New("session not found or revoked")
+	ErrRefreshTokenReused     = errors.New("refresh token was already used")
+	ErrRefreshTokenSuperseded = errors.New("refresh token was just rotated by another request")
+)
+
+// refreshGracePeriod is how long the refresh token replaced by a rotation is
+// still recognised. Tabs sharing the cookie jar may refresh at the same time
+// with the same token; the one that loses the race gets
+// ErrRefreshTokenSuperseded instead of revoking the session.
+const refreshGracePeriod = 30 * time.Second
+
+// HashToken returns the SHA-256 of a token. Tokens are long random JWTs, so a
+// fast unsalted hash is enough to keep them useless if the database leaks.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// deviceFromUserAgent gives a coarse, human readable device class.
+func deviceFromUserAgent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return "unknown"
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet"):
+		return "tablet"
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "android") || strings.Contains(ua, "iphone"):
+		return "mobile"
+	default:
+		return "desktop"
+	}
+}
+
+// maxTrackedAccessTokens bounds the access token IDs kept per session. Access
+// tokens live for a day, so this only matters for sessions refreshed far more
+// often than the client normally does.
+const maxTrackedAccessTokens = 50
+
+// issuedToken reads the ID and expiry of a token this server just signed.
+func issuedToken(token string) (models.IssuedToken, error) {
+	claims := &SignedDetails{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return models.IssuedToken{}, err
+	}
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return models.IssuedToken{}, errors.New("token has no jti or expiry")
+	}
+	return models.IssuedToken{ID: claims.ID, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// CreateSession records a new signed-in session for the tokens issued at
+// login. sessionId must be the id embedded in the tokens' claims.
+func CreateSession(sessionId bson.ObjectID, userId string, accessToken string, refreshToken string, expiresAt time.Time, client *mongo.Client, c *gin.Context) error {
+	access, err := issuedToken(accessToken)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c, 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	session := models.Session{
+		ID:               sessionId,
+		UserID:           userId,
+		RefreshTokenHash: HashToken(refreshToken),
+		UserAgent:        c.Request.UserAgent(),
+		Device:           deviceFromUserAgent(c.Request.UserAgent()),
+		IP:               c.ClientIP(),
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        expiresAt,
+		AccessTokens:     []models.IssuedToken{access},
+	}
+
+	var sessionCollection *mongo.Collection = database.OpenCollection("sessions", client)
+
+	_, err = sessionCollection.InsertOne(ctx, session)
+	return err
+}
+
+// RotateSession swaps the session's refresh token for a new one. The old
+// token must be the current one. The token it replaced within the last
+// refreshGracePeriod gives ErrRefreshTokenSuperseded and changes nothing;
+// any earlier token of the same session means it was stolen or replayed, so
+// the whole session is revoked and ErrRefreshTokenReused is returned.
+func RotateSession(sessionId string, oldRefreshToken string, newAccessToken string, newRefreshToken string, expiresAt time.Time, client *mongo.Client, c *gin.Context) error {
+	id, err := bson.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+
+	access, err := issuedToken(newAccessToken)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c, 100*time.Second)
+	defer cancel()
+
+	var sessionCollection *mongo.Collection = database.OpenCollection("sessions", client)
+
+	now := time.Now()
+	active := bson.M{
+		"_id":        id,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}
+
+	oldHash := HashToken(oldRefreshToken)
+	filter := bson.M{"refresh_token_hash": oldHash}
+	for k, v := range active {
+		filter[k] = v
+	}
+
+	result, err := sessionCollection.UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{
+			"refresh_token_hash":          HashToken(newRefreshToken),
+			"previous_refresh_token_hash": oldHash,
+			"rotated_at":                  now,
+			"user_agent":                  c.Request.UserAgent(),
+			"device":                      deviceFromUserAgent(c.Request.UserAgent()),
+			"ip":                          c.ClientIP(),
+			"last_used_at":                now,
+			"expires_at":                  expiresAt,
+		},
+		"$push": bson.M{"access_tokens": bson.M{
+			"$each":  bson.A{access},
+			"$slice": -maxTrackedAccessTokens,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 1 {
+		return nil
+	}
+
+	var session models.Session
+	if err := sessionCollection.FindOne(ctx, active).Decode(&session); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if err := staleRefreshTokenError(session, oldHash, now); !errors.Is(err, ErrRefreshTokenReused) {
+		return err
+	}
+
+	revoked, err := revokeSessions(ctx, client, active, models.SessionRevokedReuse)
+	if err != nil {
+		return err
+	}
+	if revoked > 0 {
+		return ErrRefreshTokenReused
+	}
+	return ErrSessionNotFound
+}
+
+// staleRefreshTokenError explains why a validly signed refresh token for a
+// live session is not its current one: either it was rotated a moment ago by
+// a concurrent request, or it has been used before.
+func staleRefreshTokenError(session models.Session, tokenHash string, now time.Time) error {
+	if session.PreviousRefreshTokenHash == tokenHash && session.RotatedAt != nil && now.Sub(*session.RotatedAt) < refreshGracePeriod {
+		return ErrRefreshTokenSuperseded
+	}
+	return ErrRefreshTokenReused
+}
+
+// RevokeSession ends one of the user's sessions. It reports whether an active
+// session was revoked.
+func RevokeSession(ctx context.Context, client *mongo.Client, userId string, sessionId bson.ObjectID, reason string) (bool, error) {
+	revoked, err := revokeSessions(ctx, client,
+		bson.M{"_id": sessionId, "user_id": userId, "revoked_at": bson.M{"$exists": false}},
+		reason,
+	)
+	return revoked > 0, err
+}
+
+// RevokeOtherSessions ends every active session of the user except keep,
+// which may be the zero ObjectID to end all of them.
+func RevokeOtherSessions(ctx context.Context, client *mongo.Client, userId string, keep bson.ObjectID, reason string) error {
+	_, err := revokeSessions(ctx, client,
+		bson.M{"user_id": userId, "_id": bson.M{"$ne": keep}, "revoked_at": bson.M{"$exists": false}},
+		reason,
+	)
+	return err
+}
+
+// revokeSessions marks the sessions matching filter as revoked and puts their
+// unexpired access tokens on the denylist, so that they stop working before
+// they expire. It returns how many sessions were revoked.
+func revokeSessions(ctx context.Context, client *mongo.Client, filter bson.M, reason string) (int, error) {
+	var sessionCollection *mongo.Collection = database.OpenCollection("sessions", client)
+
+	cursor, err := sessionCollection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var matched []models.Session
+	if err := cursor.All(ctx, &matched); err != nil {
+		return 0, err
+	}
+	if len(matched) == 0 {
+		return 0, nil
+	}
+
+	ids := make(bson.A, 0, len(matched))
+	for _, s := range matched {
+		ids = append(ids, s.ID)
+	}
+
+	_, err = sessionCollection.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now(), "revoked_reason": reason}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	// Read the tokens only now: a revoked session cannot be rotated, so no
+	// token can be added after this point.
+	cursor, err = sessionCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	var sessions []models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return 0, err
+	}
+
+	for _, s := range sessions {
+		for _, t := range s.AccessTokens {
+			if err := RevokeToken(ctx, client, t, s.ID.Hex()); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(sessions), nil
+}
+
+// RevokeToken adds a token to the denylist until it expires. Expired tokens
+// are rejected anyway and are not recorded.
+func RevokeToken(ctx context.Context, client *mongo.Client, token models.IssuedToken, sessionId string) error {
+	if !token.ExpiresAt.After(time.Now()) {
+		return nil
+	}
+
+	var revokedCollection *mongo.Collection = database.OpenCollection("revoked_tokens", client)
+
+	_, err := revokedCollection.UpdateOne(ctx,
+		bson.M{"jti": token.ID},
+		bson.M{"$setOnInsert": models.RevokedToken{
+			ID:        token.ID,
+			SessionID: sessionId,
+			ExpiresAt: token.ExpiresAt,
+			RevokedAt: time.Now(),
+		}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+// IsTokenRevoked reports whether the token with the given jti is on the
+// denylist.
+func IsTokenRevoked(ctx context.Context, client *mongo.Client, jti string) (bool, error) {
+	var revokedCollection *mongo.Collection = database.OpenCollection("revoked_tokens", client)
+
+	count, err := revokedCollection.CountDocuments(ctx, bson.M{"jti": jti}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ActiveSessions lists the user's sessions that are neither revoked nor
+// expired, most recently used first.
+func ActiveSessions(ctx context.Context, client *mongo.Client, userId string) ([]models.Session, error) {
+	var sessionCollection *mongo.Collection = database.OpenCollection("sessions", client)
+
+	opts := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}})
+
+	cursor, err := sessionCollection.Find(ctx, bson.M{
+		"user_id":    userId,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func GetSessionIdFromContext(c *gin.Context) (string, error) {
+	sessionId, exist := c.Get("sessionId")
+	if !exist {
+		return "", errors.New("sessionId does not exist in this context")
+	}
+
+	id, ok := sessionId.(string)
+	if !ok {
+		return "", errors.New("unable to retrieve sessionId")
+	}
+
+	return id, nil
+}
+
+// GetAccessTokenFromContext returns the access token the request was
+// authenticated with. It is absent for requests made with an API key.
+func GetAccessTokenFromContext(c *gin.Context) (models.IssuedToken, error) {
+	value, exist := c.Get("accessToken")
+	if !exist {
+		return models.IssuedToken{}, errors.New("accessToken does not exist in this context")
+	}
+
+	token, ok := value.(models.IssuedToken)
+	if !ok {
+		return models.IssuedToken{}, errors.New("unable to retrieve accessToken")
+	}
+
+	return token, nil
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+)
+
+func TestStaleRefreshTokenError(t *testing.T) {
+	rotatedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	first, second, third := HashToken("first"), HashToken("second"), HashToken("third")
+
+	// The session was rotated from the first token to the second, and then
+	// from the second to the third.
+	session := models.Session{
+		RefreshTokenHash:         third,
+		PreviousRefreshTokenHash: second,
+		RotatedAt:                &rotatedAt,
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		at    time.Time
+		want  error
+	}{
+		{"token replaced a moment ago", second, rotatedAt.Add(time.Second), ErrRefreshTokenSuperseded},
+		{"token replaced after the grace period", second, rotatedAt.Add(refreshGracePeriod), ErrRefreshTokenReused},
+		{"older token", first, rotatedAt.Add(time.Second), ErrRefreshTokenReused},
+		{"unknown token", HashToken("forged"), rotatedAt.Add(time.Second), ErrRefreshTokenReused},
+	}
+	for _, tt := range tests {
+		if err := staleRefreshTokenError(session, tt.token, tt.at); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestStaleRefreshTokenErrorBeforeFirstRotation(t *testing.T) {
+	session := models.Session{RefreshTokenHash: HashToken("current")}
+
+	if err := staleRefreshTokenError(session, "", time.Now()); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("empty hash on a session never rotated: got %v, want ErrRefreshTokenReused", err)
+	}
+}
@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// minRSAKeyBits is the smallest RSA modulus accepted for signing keys.
+const minRSAKeyBits = 2048
+
+// SigningKey is one entry of the key set. Keys without a private part can
+// only verify, which is how a retired key keeps validating the tokens it
+// signed until they expire.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+	NotBefore  time.Time
+	NotAfter   time.Time
+}
+
+// usableAt reports whether tokens signed with the key are accepted at t.
+func (k *SigningKey) usableAt(t time.Time) bool {
+	return k.NotAfter.IsZero() || t.Before(k.NotAfter)
+}
+
+// keyFileEntry is one key in the JWT_KEYS_FILE manifest. Relative file paths
+// are resolved against the manifest's directory.
+//
+//	[
+//	  {"kid": "2026-09", "file": "2026-09.pub.pem", "not_after": "2026-10-08T00:00:00Z"},
+//	  {"kid": "2026-10", "file": "2026-10.pem", "not_before": "2026-10-01T00:00:00Z"},
+//	  {"kid": "2026-11", "file": "2026-11.pem", "not_before": "2026-11-01T00:00:00Z"}
+//	]
+//
+// The newest key whose not_before has passed signs new tokens. Upcoming keys
+// are published in the JWKS before they start signing, so verifiers have them
+// cached by the time they are used; a retired key stays published until its
+// not_after, which should be at least RefreshTokenTTL after it stopped
+// signing. Keys are generated with e.g.
+//
+//	openssl genpkey -algorithm ed25519 -out 2026-11.pem
+//	openssl genpkey -algorithm rsa -pkeyopt rsa_keygen_bits:3072 -out 2026-11.pem
+type keyFileEntry struct {
+	ID        string    `json:"kid"`
+	File      string    `json:"file"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+var (
+	keysMu      sync.RWMutex
+	signingKeys []*SigningKey
+)
+
+// LoadSigningKeys reads the key set named by JWT_KEYS_FILE. It must be called
+// after the environment is loaded and before any token is issued or checked,
+// and fails unless at least one key is able to sign.
+func LoadSigningKeys() error {
+	path := os.Getenv("JWT_KEYS_FILE")
+	if path == "" {
+		return errors.New("JWT_KEYS_FILE is not set")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading JWT_KEYS_FILE: %w", err)
+	}
+
+	var entries []keyFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("parsing JWT_KEYS_FILE: %w", err)
+	}
+
+	keys := make([]*SigningKey, 0, len(entries))
+	seen := map[string]bool{}
+	canSign := false
+	for _, entry := range entries {
+		if entry.ID == "" {
+			return errors.New("every key in JWT_KEYS_FILE needs a kid")
+		}
+		if seen[entry.ID] {
+			return fmt.Errorf("duplicate kid %q in JWT_KEYS_FILE", entry.ID)
+		}
+		seen[entry.ID] = true
+
+		file := entry.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+
+		key, err := readSigningKey(file)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", entry.ID, err)
+		}
+		key.ID = entry.ID
+		key.NotBefore = entry.NotBefore
+		key.NotAfter = entry.NotAfter
+
+		if key.PrivateKey != nil && !key.NotBefore.After(time.Now()) && key.usableAt(time.Now()) {
+			canSign = true
+		}
+		keys = append(keys, key)
+	}
+
+	if !canSign {
+		return errors.New("JWT_KEYS_FILE has no private key that can sign now")
+	}
+
+	// Newest first, so that currentSigningKey picks the latest active key.
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].NotBefore.After(keys[j].NotBefore)
+	})
+
+	keysMu.Lock()
+	signingKeys = keys
+	keysMu.Unlock()
+	return nil
+}
+
+// readSigningKey parses a PEM file holding an RSA or Ed25519 private key, or
+// only the public key for a key that no longer signs.
+func readSigningKey(file string) (*SigningKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	key := &SigningKey{}
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.PrivateKey, key.PublicKey = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.PrivateKey, key.PublicKey = k, k.Public()
+	case *rsa.PublicKey, ed25519.PublicKey:
+		key.PublicKey = k
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	switch k := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must have at least %d bits", minRSAKeyBits)
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	}
+
+	return key, nil
+}
+
+// currentSigningKey returns the newest key that may sign at the moment.
+func currentSigningKey() (*SigningKey, error) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	now := time.Now()
+	for _, key := range signingKeys {
+		if key.PrivateKey != nil && !key.NotBefore.After(now) && key.usableAt(now) {
+			return key, nil
+		}
+	}
+	return nil, errors.New("no signing key is active")
+}
+
+// verificationKey is the jwt.Keyfunc for tokens issued by this server. The
+// token must name a known kid and use that key's algorithm.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	for _, key := range signingKeys {
+		if key.ID != kid {
+			continue
+		}
+		if !key.usableAt(time.Now()) {
+			return nil, errors.New("signing key has been retired")
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.PublicKey, nil
+	}
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+// JSONWebKey is the public part of a signing key in RFC 7517 form.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// PublicJWKS lists every key that is or will be accepted, including keys
+// scheduled to start signing later.
+func PublicJWKS() []JSONWebKey {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	now := time.Now()
+	jwks := []JSONWebKey{}
+	for _, key := range signingKeys {
+		if !key.usableAt(now) {
+			continue
+		}
+
+		jwk := JSONWebKey{Use: "sig", Alg: key.Method.Alg(), Kid: key.ID}
+		switch k := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// writePEM stores the private key, or only its public part, in dir.
+func writePEM(t *testing.T, dir string, name string, key crypto.Signer, publicOnly bool) {
+	t.Helper()
+
+	var block *pem.Block
+	if publicOnly {
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loadKeys writes a JWT_KEYS_FILE manifest for entries into dir and loads it.
+// The key set in use before the test is restored afterwards.
+func loadKeys(t *testing.T, dir string, entries []keyFileEntry) error {
+	t.Helper()
+
+	keysMu.RLock()
+	saved := signingKeys
+	keysMu.RUnlock()
+	t.Cleanup(func() {
+		keysMu.Lock()
+		signingKeys = saved
+		keysMu.Unlock()
+	})
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(dir, "keys.json")
+	if err := os.WriteFile(manifest, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_KEYS_FILE", manifest)
+
+	return LoadSigningKeys()
+}
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func accessClaims() *SignedDetails {
+	return &SignedDetails{
+		UserId:    "u1",
+		TokenType: tokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "t1",
+			Issuer:    tokenIssuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+func tokenKid(t *testing.T, token string) (string, string) {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &SignedDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid, parsed.Method.Alg()
+}
+
+func TestNewestActiveKeySigns(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writePEM(t, dir, "rsa.pem", newRSAKey(t, 2048), false)
+	writePEM(t, dir, "ed.pem", newEd25519Key(t), false)
+	writePEM(t, dir, "next.pem", newEd25519Key(t), false)
+
+	err := loadKeys(t, dir, []keyFileEntry{
+		{ID: "rsa", File: "rsa.pem", NotBefore: now.Add(-48 * time.Hour)},
+		{ID: "ed", File: filepath.Join(dir, "ed.pem"), NotBefore: now.Add(-time.Hour)},
+		{ID: "next", File: "next.pem", NotBefore: now.Add(24 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("LoadSigningKeys: %v", err)
+	}
+
+	token, refreshToken, err := GenerateAllTokens("jane@example.com", "Jane", "Doe", "USER", "u1", "s1")
+	if err != nil {
+		t.Fatalf("GenerateAllTokens: %v", err)
+	}
+	if kid, alg := tokenKid(t, token); kid != "ed" || alg != jwt.SigningMethodEdDSA.Alg() {
+		t.Errorf("token signed by %s with %s, want ed with EdDSA", kid, alg)
+	}
+
+	claims, err := ValidateToken(token)
+	if err != nil || claims.UserId != "u1" {
+		t.Fatalf("ValidateToken = %+v, %v", claims, err)
+	}
+	if _, err := ValidateToken(refreshToken); err == nil {
+		t.Error("a refresh token was accepted as an access token")
+	}
+	if _, err := ValidateRefreshToken(refreshToken); err != nil {
+		t.Errorf("ValidateRefreshToken: %v", err)
+	}
+
+	// Upcoming keys are published before they start signing.
+	jwks := map[string]JSONWebKey{}
+	for _, jwk := range PublicJWKS() {
+		jwks[jwk.Kid] = jwk
+	}
+	if len(jwks) != 3 {
+		t.Fatalf("JWKS has %d keys, want 3", len(jwks))
+	}
+	if rsaKey := jwks["rsa"]; rsaKey.Kty != "RSA" || rsaKey.Alg != "RS256" || rsaKey.N == "" || rsaKey.E != "AQAB" {
+		t.Errorf("RSA JWK = %+v", rsaKey)
+	}
+	if edKey := jwks["next"]; edKey.Kty != "OKP" || edKey.Crv != "Ed25519" || edKey.Alg != "EdDSA" || edKey.X == "" {
+		t.Errorf("Ed25519 JWK = %+v", edKey)
+	}
+}
+
+func TestRetiredKeyVerifiesUntilNotAfter(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	oldKey := newRSAKey(t, 2048)
+
+	writePEM(t, dir, "old.pem", oldKey, false)
+	if err := loadKeys(t, dir, []keyFileEntry{{ID: "old", File: "old.pem"}}); err != nil {
+		t.Fatalf("LoadSigningKeys: %v", err)
+	}
+	oldToken, _, err := GenerateAllTokens("jane@example.com", "Jane", "Doe", "USER", "u1", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotation: the old key keeps only its public part and verifies until
+	// its not_after, while the new one signs.
+	writePEM(t, dir, "old.pub.pem", oldKey, true)
+	writePEM(t, dir, "new.pem", newEd25519Key(t), false)
+	err = loadKeys(t, dir, []keyFileEntry{
+		{ID: "old", File: "old.pub.pem", NotAfter: now.Add(time.Hour)},
+		{ID: "new", File: "new.pem", NotBefore: now.Add(-time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("LoadSigningKeys: %v", err)
+	}
+
+	if _, err := ValidateToken(oldToken); err != nil {
+		t.Errorf("token of the retired key during the overlap: %v", err)
+	}
+	newToken, _, err := GenerateAllTokens("jane@example.com", "Jane", "Doe", "USER", "u1", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid, _ := tokenKid(t, newToken); kid != "new" {
+		t.Errorf("new token signed by %s, want new", kid)
+	}
+
+	// After not_after the key is gone from verification and the JWKS.
+	err = loadKeys(t, dir, []keyFileEntry{
+		{ID: "old", File: "old.pub.pem", NotAfter: now.Add(-time.Second)},
+		{ID: "new", File: "new.pem", NotBefore: now.Add(-time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("LoadSigningKeys: %v", err)
+	}
+	if _, err := ValidateToken(oldToken); err == nil {
+		t.Error("token of a key past its not_after was accepted")
+	}
+	for _, jwk := range PublicJWKS() {
+		if jwk.Kid == "old" {
+			t.Error("key past its not_after is still in the JWKS")
+		}
+	}
+}
+
+func TestVerificationRejectsUnknownKidAndAlg(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := newRSAKey(t, 2048)
+	edKey := newEd25519Key(t)
+
+	writePEM(t, dir, "rsa.pem", rsaKey, false)
+	writePEM(t, dir, "ed.pem", edKey, false)
+	err := loadKeys(t, dir, []keyFileEntry{
+		{ID: "rsa", File: "rsa.pem"},
+		{ID: "ed", File: "ed.pem", NotBefore: time.Now().Add(-time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("LoadSigningKeys: %v", err)
+	}
+
+	sign := func(method jwt.SigningMethod, kid string, key any) string {
+		token := jwt.NewWithClaims(method, accessClaims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ValidateToken(sign(jwt.SigningMethodRS256, "rsa", rsaKey)); err != nil {
+		t.Fatalf("well-formed token rejected: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"unknown kid", sign(jwt.SigningMethodEdDSA, "other", edKey)},
+		{"no kid", sign(jwt.SigningMethodEdDSA, "", edKey)},
+		{"alg of another key", sign(jwt.SigningMethodEdDSA, "rsa", edKey)},
+		{"RSA key claimed as PS256", sign(jwt.SigningMethodPS256, "rsa", rsaKey)},
+		{"HMAC with the public key", sign(jwt.SigningMethodHS256, "rsa", rsaPublic)},
+		{"alg none", sign(jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		if _, err := ValidateToken(tt.token); err == nil {
+			t.Errorf("%s: token was accepted", tt.name)
+		}
+	}
+}
+
+func TestLoadSigningKeysRejectsBadKeySets(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writePEM(t, dir, "small.pem", newRSAKey(t, 1024), false)
+	writePEM(t, dir, "ed.pem", newEd25519Key(t), false)
+	writePEM(t, dir, "ed.pub.pem", newEd25519Key(t), true)
+
+	tests := []struct {
+		name    string
+		entries []keyFileEntry
+	}{
+		{"RSA key below 2048 bits", []keyFileEntry{{ID: "small", File: "small.pem"}}},
+		{"duplicate kid", []keyFileEntry{{ID: "a", File: "ed.pem"}, {ID: "a", File: "ed.pem"}}},
+		{"missing kid", []keyFileEntry{{File: "ed.pem"}}},
+		{"only public keys", []keyFileEntry{{ID: "pub", File: "ed.pub.pem"}}},
+		{"only future keys", []keyFileEntry{{ID: "next", File: "ed.pem", NotBefore: now.Add(time.Hour)}}},
+		{"missing file", []keyFileEntry{{ID: "gone", File: "gone.pem"}}},
+	}
+	for _, tt := range tests {
+		if err := loadKeys(t, dir, tt.entries); err == nil {
+			t.Errorf("%s: LoadSigningKeys succeeded", tt.name)
+		}
+	}
+}
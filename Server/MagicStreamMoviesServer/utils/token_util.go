@@ -1,16 +1,13 @@
 package utils
 
 import (
-	"context"
 	"errors"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
-	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
 	"go.mongodb.org/mongo-driver/v2/bson"
-	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 type SignedDetails struct {
@@ -19,27 +16,50 @@ type SignedDetails struct {
 	LastName  string
 	Role      string
 	UserId    string
+	SessionId string
+	TokenType string
 	jwt.RegisteredClaims
 }
 
-var secretKey string = os.Getenv("SECRET_KEY")
-var refreshSecretKey string = os.Getenv("SECRET_REFRESH_KEY")
+const (
+	AccessTokenTTL  = 24 * time.Hour
+	RefreshTokenTTL = 24 * 7 * time.Hour
+)
+
+// Access and refresh tokens are signed with the same keys, so the token type
+// is what stops a refresh token from being used as an access token.
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+const tokenIssuer = "MagicStream"
+
+// GenerateAllTokens issues an access and a refresh token for the session.
+// Every token gets its own ID, so two tokens issued in the same second for
+// the same session still differ.
+func GenerateAllTokens(email, firstName, lastName, role, userId, sessionId string) (string, string, error) {
+	key, err := currentSigningKey()
+	if err != nil {
+		return "", "", err
+	}
 
-func GenerateAllTokens(email, firstName, lastName, role, userId string) (string, string, error) {
 	claims := &SignedDetails{
 		Email:     email,
 		FirstName: firstName,
 		LastName:  lastName,
 		Role:      role,
 		UserId:    userId,
+		SessionId: sessionId,
+		TokenType: tokenTypeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    "MagicStream",
+			ID:        bson.NewObjectID().Hex(),
+			Issuer:    tokenIssuer,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(secretKey))
+	signedToken, err := signToken(key, claims)
 
 	if err != nil {
 		return "", "", err
@@ -51,14 +71,16 @@ func GenerateAllTokens(email, firstName, lastName, role, userId string) (string,
 		LastName:  lastName,
 		Role:      role,
 		UserId:    userId,
+		SessionId: sessionId,
+		TokenType: tokenTypeRefresh,
 		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    "MagicStream",
+			ID:        bson.NewObjectID().Hex(),
+			Issuer:    tokenIssuer,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 7 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(RefreshTokenTTL)),
 		},
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	signedRefreshToken, err := refreshToken.SignedString([]byte(refreshSecretKey))
+	signedRefreshToken, err := signToken(key, refreshClaims)
 
 	if err != nil {
 		return "", "", err
@@ -67,66 +89,62 @@ func GenerateAllTokens(email, firstName, lastName, role, userId string) (string,
 	return signedToken, signedRefreshToken, nil
 
 }
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userId string, client *mongo.Client, c *gin.Context) (err error) {
-	ctx, cancel := context.WithTimeout(c, 100*time.Second)
-	defer cancel()
-
-	updateAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-
-	updateData := bson.M{
-		"token":         signedToken,
-		"refresh_token": signedRefreshToken,
-		"updated_at":    updateAt,
-	}
 
-	var userCollection *mongo.Collection = database.OpenCollection("users", client)
-
-	_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": userId}, bson.M{"$set": updateData})
-	if err != nil {
-		return err
-	}
-	return nil
+func signToken(key *SigningKey, claims *SignedDetails) (string, error) {
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
 }
 
-func GetAccessToken(c *gin.Context) (string, error) {
-	// authHeader := c.GetHeader("Authorization")
-	// if authHeader == "" {
-	// 	return "", errors.New("authorization header missing")
-	// }
-	// tokenString := authHeader[len("Bearer "):]
-	// if tokenString == "" {
-	// 	return "", errors.New("bearer token missing")
-	// }
-	tokenString, err := c.Cookie("access_token")
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
-}
-
-func ValidateToken(tokenString string) (*SignedDetails, error) {
+// parseToken verifies the token against the published keys and checks that
+// it is of the expected type.
+func parseToken(tokenString string, tokenType string) (*SignedDetails, error) {
 	claims := &SignedDetails{}
 
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		claims,
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(secretKey), nil
-		},
+		verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, ok := token.Claims.(*SignedDetails); !ok || !token.Valid {
+	if !token.Valid || claims.TokenType != tokenType {
 		return nil, errors.New("invalid token")
 	}
+	return claims, nil
+}
 
-	if claims.ExpiresAt.Time.Before(time.Now()) {
-		return nil, errors.New("token expired")
+// GetAccessToken returns the credential sent with the request: a bearer token
+// from the Authorization header, which may be a JWT or an API key, or else
+// the access_token cookie set by the browser client.
+func GetAccessToken(c *gin.Context) (string, error) {
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		scheme, tokenString, found := strings.Cut(authHeader, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return "", errors.New("authorization header must use the Bearer scheme")
+		}
+		tokenString = strings.TrimSpace(tokenString)
+		if tokenString == "" {
+			return "", errors.New("bearer token missing")
+		}
+		return tokenString, nil
 	}
-	return claims, nil
+
+	tokenString, err := c.Cookie("access_token")
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func ValidateToken(tokenString string) (*SignedDetails, error) {
+	return parseToken(tokenString, tokenTypeAccess)
 }
 
 func GetUserIdFromContext(c *gin.Context) (string, error) {
@@ -160,23 +178,5 @@ func GetRoleFromContext(c *gin.Context) (string, error) {
 }
 
 func ValidateRefreshToken(tokenString string) (*SignedDetails, error) {
-	claims := &SignedDetails{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-
-		return []byte(refreshSecretKey), nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-		return nil, err
-	}
-
-	if claims.ExpiresAt.Time.Before(time.Now()) {
-		return nil, errors.New("refresh token has expired")
-	}
-
-	return claims, nil
-}
\ No newline at end of file
+	return parseToken(tokenString, tokenTypeRefresh)
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/database"
+	"github.com/horzu/MagicStreamMovies/Server/MagicStreamMoviesServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	VerifyEmailTokenTTL   = 48 * time.Hour
+	PasswordResetTokenTTL = time.Hour
+)
+
+var ErrInvalidUserToken = errors.New("token is invalid, expired or already used")
+
+// IssueUserToken creates a token for the given purpose and returns it. Older
+// unused tokens of the same purpose stop working, so only the latest email
+// sent to the user is valid.
+func IssueUserToken(ctx context.Context, client *mongo.Client, userId string, purpose string, email string, ttl time.Duration) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	var userTokenCollection *mongo.Collection = database.OpenCollection("user_tokens", client)
+
+	now := time.Now()
+	_, err := userTokenCollection.DeleteMany(ctx, bson.M{
+		"user_id": userId,
+		"purpose": purpose,
+		"used_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_, err = userTokenCollection.InsertOne(ctx, models.UserToken{
+		UserID:    userId,
+		Purpose:   purpose,
+		TokenHash: HashToken(token),
+		Email:     email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConsumeUserToken marks a token as used and returns it. It fails with
+// ErrInvalidUserToken when the token is unknown, meant for another purpose,
+// expired or was used before.
+func ConsumeUserToken(ctx context.Context, client *mongo.Client, token string, purpose string) (models.UserToken, error) {
+	var userTokenCollection *mongo.Collection = database.OpenCollection("user_tokens", client)
+
+	now := time.Now()
+	var userToken models.UserToken
+	err := userTokenCollection.FindOneAndUpdate(ctx,
+		bson.M{
+			"token_hash": HashToken(token),
+			"purpose":    purpose,
+			"used_at":    bson.M{"$exists": false},
+			"expires_at": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"used_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&userToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return userToken, ErrInvalidUserToken
+	}
+	return userToken, err
+}